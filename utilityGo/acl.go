@@ -0,0 +1,175 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// aclModeConfig names the configuration entry enabling the per-asset access control lists
+const aclModeConfig = "aclMode"
+
+// Permissions an access control list can grant; write access includes read access
+const (
+	PermissionRead  = "read"
+	PermissionWrite = "write"
+)
+
+// SetACLMode makes the chaincode check the access control list of an asset when enabled. Reading an asset,
+// its history or query results that include it needs read access, and changing, transferring or deleting it
+// needs write access. Approvals are not covered, since approvers are rarely owners. Owners, co-owners and
+// admins always have full access. Counters and the ledger fingerprint still cover every asset. Only admins
+// may change it.
+func (s *SmartContract) SetACLMode(ctx contractapi.TransactionContextInterface, enabled bool) error {
+	return setConfigBool(ctx, aclModeConfig, enabled)
+}
+
+// GrantAccess gives the client identified by its MSP ID read or write access to the asset with given id,
+// replacing any permission it held before. Only the owner or an admin may grant access.
+func (s *SmartContract) GrantAccess(ctx contractapi.TransactionContextInterface, id, identity, permission string) error {
+	if permission != PermissionRead && permission != PermissionWrite {
+		return fmt.Errorf("Unknown permission %s. Expected %s or %s", permission, PermissionRead, PermissionWrite)
+	}
+	if strings.TrimSpace(identity) == "" {
+		return fmt.Errorf("The identity to grant access to must not be empty")
+	}
+
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = requireOwnerOrAdmin(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	if asset.ACL == nil {
+		asset.ACL = map[string]string{}
+	}
+	asset.ACL[identity] = permission
+
+	return putAsset(ctx, asset)
+}
+
+// RevokeAccess removes the permission granted to identity on the asset with given id.
+// Only the owner or an admin may revoke access.
+func (s *SmartContract) RevokeAccess(ctx contractapi.TransactionContextInterface, id, identity string) error {
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = requireOwnerOrAdmin(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := asset.ACL[identity]; !ok {
+		return fmt.Errorf("The identity %s has not been granted access to asset %s", identity, id)
+	}
+	delete(asset.ACL, identity)
+	if len(asset.ACL) == 0 {
+		asset.ACL = nil
+	}
+
+	return putAsset(ctx, asset)
+}
+
+// requireAccess returns an error when ACL mode is enabled and the caller holds neither permission on asset
+// through its access control list nor full access as an owner, co-owner or admin.
+func requireAccess(ctx contractapi.TransactionContextInterface, asset *Asset, permission string) error {
+	enabled, err := getConfigBool(ctx, aclModeConfig)
+	if err != nil || !enabled {
+		return err
+	}
+
+	callerID, err := getClientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	if hasAccess(callerID, asset, permission) {
+		return nil
+	}
+
+	admin, err := hasAdminAttribute(ctx)
+	if err != nil {
+		return err
+	}
+	if !admin {
+		return fmt.Errorf("Client %s does not have %s access to asset %s", callerID, permission, asset.ID)
+	}
+
+	return nil
+}
+
+// hasWriteGrant reports whether ACL mode is enabled and the access control list of asset grants the caller
+// write access.
+func hasWriteGrant(ctx contractapi.TransactionContextInterface, asset *Asset) (bool, error) {
+	enabled, err := getConfigBool(ctx, aclModeConfig)
+	if err != nil || !enabled {
+		return false, err
+	}
+
+	callerID, err := getClientMSPID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return asset.ACL[callerID] == PermissionWrite, nil
+}
+
+// hasAccess reports whether the client of MSP callerID owns or co-owns asset or holds permission on it
+// through its access control list.
+func hasAccess(callerID string, asset *Asset, permission string) bool {
+	if callerID == asset.Owner || containsString(asset.Owners, callerID) {
+		return true
+	}
+
+	granted := asset.ACL[callerID]
+	return granted == PermissionWrite || granted == permission
+}
+
+// readableFilter narrows match to the assets the caller may read while ACL mode is enabled and returns match
+// as it is otherwise. A nil match stands for every asset.
+func readableFilter(ctx contractapi.TransactionContextInterface, match func(*Asset) bool) (func(*Asset) bool, error) {
+	enabled, err := getConfigBool(ctx, aclModeConfig)
+	if err != nil || !enabled {
+		return match, err
+	}
+
+	admin, err := hasAdminAttribute(ctx)
+	if err != nil || admin {
+		return match, err
+	}
+
+	callerID, err := getClientMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(asset *Asset) bool {
+		return hasAccess(callerID, asset, PermissionRead) && (match == nil || match(asset))
+	}, nil
+}
+
+// readAssetForWrite reads the asset with given id for a transaction that changes it, requiring write access
+// to it in ACL mode.
+func (s *SmartContract) readAssetForWrite(ctx contractapi.TransactionContextInterface, id string) (*Asset, error) {
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = requireAccess(ctx, asset, PermissionWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	return asset, nil
+}
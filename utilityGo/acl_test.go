@@ -0,0 +1,202 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// newACLLedger returns a ledger in ACL mode holding asset1 and asset2, both owned by Org1MSP, with an owner
+// context over it.
+func newACLLedger(t *testing.T) (*testStub, *contractapi.TransactionContext) {
+	t.Helper()
+
+	stub := newTestStub()
+	owner := newTestContext(stub, "Org1MSP", admin)
+	mustCreateAsset(t, owner, "asset1", "first", "Org1MSP")
+	mustCreateAsset(t, owner, "asset2", "second", "Org1MSP")
+	err := new(SmartContract).SetACLMode(owner, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return stub, owner
+}
+
+func TestGrantAccessAllowsRead(t *testing.T) {
+	s := new(SmartContract)
+	stub, owner := newACLLedger(t)
+	reader := newTestContext(stub, "Org2MSP", member)
+
+	_, err := s.ReadAsset(reader, "asset1")
+	if err == nil {
+		t.Error("A client without a grant read the asset")
+	}
+
+	err = s.GrantAccess(owner, "asset1", "Org2MSP", PermissionRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.ReadAsset(reader, "asset1")
+	if err != nil {
+		t.Errorf("Expected the grantee to read the asset, got %s", err)
+	}
+
+	err = s.RevokeAccess(owner, "asset1", "Org2MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.ReadAsset(reader, "asset1")
+	if err == nil {
+		t.Error("A client read the asset after its access was revoked")
+	}
+	err = s.RevokeAccess(owner, "asset1", "Org2MSP")
+	if err == nil {
+		t.Error("Revoking access that was never granted succeeded")
+	}
+}
+
+func TestGrantAccessValidatesArguments(t *testing.T) {
+	s := new(SmartContract)
+	stub, owner := newACLLedger(t)
+
+	err := s.GrantAccess(owner, "asset1", "Org2MSP", "own")
+	if err == nil {
+		t.Error("An unknown permission was granted")
+	}
+	err = s.GrantAccess(owner, "asset1", " ", PermissionRead)
+	if err == nil {
+		t.Error("Access was granted to an empty identity")
+	}
+	err = s.GrantAccess(newTestContext(stub, "Org2MSP", member), "asset1", "Org2MSP", PermissionRead)
+	if err == nil {
+		t.Error("A client other than the owner granted access")
+	}
+}
+
+func TestReadAccessDeniesWrites(t *testing.T) {
+	s := new(SmartContract)
+	stub, owner := newACLLedger(t)
+	reader := newTestContext(stub, "Org2MSP", member)
+	err := s.GrantAccess(owner, "asset1", "Org2MSP", PermissionRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.UpdateAsset(reader, "asset1", "changed", "Org1MSP", 0, 0, 0)
+	if err == nil || !strings.Contains(err.Error(), "write access") {
+		t.Errorf("Expected the update to need write access, got %v", err)
+	}
+	swapped, err := s.CompareAndSwapField(reader, "asset1", "description", "first", "changed")
+	if err == nil || swapped {
+		t.Error("A read-only grantee swapped a field")
+	}
+	err = s.TransferAsset(reader, "asset1", "Org2MSP")
+	if err == nil {
+		t.Error("A read-only grantee transferred the asset")
+	}
+	err = s.DeleteAsset(reader, "asset1")
+	if err == nil {
+		t.Error("A read-only grantee deleted the asset")
+	}
+	err = s.AddComment(reader, "asset1", "hello")
+	if err == nil {
+		t.Error("A read-only grantee commented on the asset")
+	}
+
+	simulation, err := s.SimulateTransfer(reader, "asset1", "Org2MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if simulation.WouldSucceed {
+		t.Error("The simulation let a read-only grantee transfer the asset")
+	}
+}
+
+func TestWriteAccessAllowsChanges(t *testing.T) {
+	s := new(SmartContract)
+	stub, owner := newACLLedger(t)
+	writer := newTestContext(stub, "Org3MSP", member)
+	err := s.GrantAccess(owner, "asset1", "Org3MSP", PermissionWrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.UpdateAsset(writer, "asset1", "changed", "Org1MSP", 0, 0, 0)
+	if err != nil {
+		t.Errorf("Expected the write grantee to update the asset, got %s", err)
+	}
+	err = s.TransferAsset(writer, "asset1", "Org3MSP")
+	if err != nil {
+		t.Errorf("Expected the write grantee to transfer the asset, got %s", err)
+	}
+}
+
+func TestACLModeFiltersQueries(t *testing.T) {
+	s := new(SmartContract)
+	stub, owner := newACLLedger(t)
+	reader, stranger := newTestContext(stub, "Org2MSP", member), newTestContext(stub, "Org3MSP", member)
+	err := s.GrantAccess(owner, "asset1", "Org2MSP", PermissionRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := s.GetAllAssets(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Key != "asset1" {
+		t.Errorf("Expected only the granted asset1, got %+v", results)
+	}
+	results, err = s.GetAllAssets(stranger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no assets for a client without grants, got %+v", results)
+	}
+
+	_, err = s.GetAssetHistoryPage(stranger, "asset1", 0, 10)
+	if err == nil {
+		t.Error("A client without a grant read the history of the asset")
+	}
+
+	count, err := s.CountAssets(stranger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("Expected the counter to cover every asset, got %d", count)
+	}
+}
+
+func TestACLModeDoesNotCoverApprovals(t *testing.T) {
+	s := new(SmartContract)
+	stub, owner := newACLLedger(t)
+	err := s.SubmitForApproval(owner, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.ApproveRequestOne(newTestContext(stub, "Org2MSP", member), "asset1")
+	if err != nil {
+		t.Fatalf("Expected an approver without a grant to approve, got %s", err)
+	}
+	err = s.ApproveRequestTwo(newTestContext(stub, "Org3MSP", member), "asset1")
+	if err != nil {
+		t.Fatalf("Expected an approver without a grant to approve, got %s", err)
+	}
+
+	asset, err := s.ReadAsset(owner, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Registered != 1 {
+		t.Errorf("Expected the approvals to register the asset, got %+v", asset)
+	}
+}
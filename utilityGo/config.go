@@ -0,0 +1,118 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// adminMSPID is the MSP whose clients may perform administrative operations
+const adminMSPID = "Org1MSP"
+
+// configKeyPrefix prefixes the world state keys holding chaincode configuration
+const configKeyPrefix = internalKeyPrefix + "config~"
+
+// getConfig returns the stored value of the named configuration entry, or an empty string when it is not set.
+func getConfig(ctx contractapi.TransactionContextInterface, key string) (string, error) {
+	value, err := ctx.GetStub().GetState(configKeyPrefix + key)
+	if err != nil {
+		return "", fmt.Errorf("Failed to read configuration %s. %s", key, err.Error())
+	}
+
+	return string(value), nil
+}
+
+// setConfig stores value as the named configuration entry. Callers are responsible for checking
+// that the client may change it; transactions should normally go through setAdminConfig.
+func setConfig(ctx contractapi.TransactionContextInterface, key, value string) error {
+	err := ctx.GetStub().PutState(configKeyPrefix+key, []byte(value))
+	if err != nil {
+		return fmt.Errorf("Failed to put configuration %s. %s", key, err.Error())
+	}
+
+	return nil
+}
+
+// setAdminConfig stores value as the named configuration entry if the client belongs to the admin MSP.
+func setAdminConfig(ctx contractapi.TransactionContextInterface, key, value string) error {
+	err := requireAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	return setConfig(ctx, key, value)
+}
+
+// getConfigBool returns the named configuration entry as a bool. An unset entry is false.
+func getConfigBool(ctx contractapi.TransactionContextInterface, key string) (bool, error) {
+	value, err := getConfig(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if value == "" {
+		return false, nil
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("The configuration %s is not a valid bool. %s", key, err.Error())
+	}
+
+	return enabled, nil
+}
+
+// setConfigBool stores the named configuration entry as a bool if the client belongs to the admin MSP.
+func setConfigBool(ctx contractapi.TransactionContextInterface, key string, value bool) error {
+	return setAdminConfig(ctx, key, strconv.FormatBool(value))
+}
+
+// getConfigInt returns the named configuration entry as an int, or fallback when it is not set.
+func getConfigInt(ctx contractapi.TransactionContextInterface, key string, fallback int) (int, error) {
+	value, err := getConfig(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	if value == "" {
+		return fallback, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("The configuration %s is not a valid integer. %s", key, err.Error())
+	}
+
+	return number, nil
+}
+
+// setConfigInt stores the named configuration entry as an int if the client belongs to the admin MSP.
+func setConfigInt(ctx contractapi.TransactionContextInterface, key string, value int) error {
+	return setAdminConfig(ctx, key, strconv.Itoa(value))
+}
+
+// requireAdmin returns an error unless the client submitting the transaction belongs to the admin MSP.
+func requireAdmin(ctx contractapi.TransactionContextInterface) error {
+	mspID, err := getClientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	if mspID != adminMSPID {
+		return fmt.Errorf("Client of MSP %s is not authorized to perform this operation", mspID)
+	}
+
+	return nil
+}
+
+// hasAdminAttribute reports whether the client submitting the transaction carries the admin attribute.
+func hasAdminAttribute(ctx contractapi.TransactionContextInterface) (bool, error) {
+	value, found, err := ctx.GetClientIdentity().GetAttributeValue("admin")
+	if err != nil {
+		return false, fmt.Errorf("Failed to read client attributes. %s", err.Error())
+	}
+
+	return found && value == "true", nil
+}
@@ -0,0 +1,73 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+func TestConfigRoundTrips(t *testing.T) {
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+
+	err := setConfigInt(ctx, "quota", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	quota, err := getConfigInt(ctx, "quota", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quota != 5 {
+		t.Errorf("Expected the stored quota 5, got %d", quota)
+	}
+	unset, err := getConfigInt(ctx, "unset", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unset != 7 {
+		t.Errorf("Expected the fallback 7, got %d", unset)
+	}
+
+	enabled, err := getConfigBool(ctx, "toggle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enabled {
+		t.Error("An unset toggle is enabled")
+	}
+	err = setConfigBool(ctx, "toggle", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enabled, err = getConfigBool(ctx, "toggle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !enabled {
+		t.Error("The stored toggle is not enabled")
+	}
+}
+
+func TestConfigRequiresAdmin(t *testing.T) {
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org2MSP", admin)
+
+	err := setConfigBool(ctx, "toggle", true)
+	if err == nil {
+		t.Error("A client outside the admin MSP changed a toggle")
+	}
+	err = setConfigInt(ctx, "quota", 5)
+	if err == nil {
+		t.Error("A client outside the admin MSP changed a quota")
+	}
+
+	value, err := getConfig(ctx, "toggle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "" {
+		t.Errorf("The rejected change was stored: %s", value)
+	}
+}
@@ -5,12 +5,191 @@ SPDX-License-Identifier: Apache-2.0
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
+// requiredApprovals is the number of approvals an asset without named approvers needs before it is registered
+const requiredApprovals = 2
+
+// internalKeyPrefix prefixes the world state keys the chaincode keeps for its own bookkeeping
+const internalKeyPrefix = "~"
+
+// compositeKeyNamespace is the prefix Fabric puts in front of every composite key
+const compositeKeyNamespace = "\x00"
+
+// maxApprovalLogEntries bounds the approval log kept on each asset; the oldest entries are dropped first
+const maxApprovalLogEntries = 100
+
+// maxBatchOperations bounds the number of operations ExecuteBatch runs in one transaction
+const maxBatchOperations = 100
+
+// maxCommentLength bounds the length in bytes of a single comment
+const maxCommentLength = 1000
+
+// maxComments bounds the number of comments an asset can hold; comments are never dropped
+const maxComments = 100
+
+// maxRecentRegistrations caps the number of assets GetRecentRegistrations returns
+const maxRecentRegistrations = 100
+
+// maxAssetsLimit bounds the limit GetAssetsLimited accepts
+const maxAssetsLimit = 1000
+
+// maxBulkReadIDs bounds the number of IDs ReadAssetsDetailed reads in one call
+const maxBulkReadIDs = 100
+
+// chaincodeVersion identifies the release of this chaincode and is bumped with every release
+const chaincodeVersion = "1.0.0"
+
+// assetSchemaVersion is the version of the Asset record layout; MigrateAssets brings older records up to it
+const assetSchemaVersion = 1
+
+// maxBulkUpdateAssets bounds the number of assets UpdateAssets writes in one transaction
+const maxBulkUpdateAssets = 100
+
+// eventSchemaVersion is the version of the EventEnvelope layout, raised whenever a field changes meaning or is removed
+const eventSchemaVersion = 1
+
+// ownerMSPAllowlistConfig names the configuration entry listing the MSP IDs allowed to own assets
+const ownerMSPAllowlistConfig = "ownerMSPAllowlist"
+
+// idStrategyConfig names the configuration entry selecting how CreateAssetAutoID generates IDs
+const idStrategyConfig = "idStrategy"
+
+// resetApprovalsOnTransferConfig names the configuration entry making transfers withdraw the approvals of an asset
+const resetApprovalsOnTransferConfig = "resetApprovalsOnTransfer"
+
+// strictModeConfig names the configuration entry enabling the detailed error for unknown functions
+const strictModeConfig = "strictMode"
+
+// ID generation strategies supported by CreateAssetAutoID
+const (
+	IDStrategyTxHash     = "txhash"
+	IDStrategySequential = "sequential"
+)
+
+// Creation sources recorded on new assets, telling IDs the caller chose from IDs the chaincode generated
+const (
+	CreationSourceManual = "manual"
+	CreationSourceAuto   = "auto"
+)
+
+// counterKeyPrefix prefixes the world state keys holding internal counters
+const counterKeyPrefix = internalKeyPrefix + "counter~"
+
+// assetIDCounter names the counter backing the sequential ID strategy
+const assetIDCounter = "assetID"
+
+// totalAssetsCounter names the counter tracking how many assets exist in world state
+const totalAssetsCounter = "totalAssets"
+
+// featureToggles lists the boolean configuration entries GetChaincodeInfo reports on
+var featureToggles = []string{
+	aclModeConfig,
+	maintenanceModeConfig,
+	resetApprovalsOnTransferConfig,
+	strictModeConfig,
+	tenantIsolationConfig,
+}
+
+// queryableAssetFields lists the string valued asset JSON fields QueryAssetsByField can match on
+var queryableAssetFields = map[string]bool{
+	"ID":             true,
+	"description":    true,
+	"owner":          true,
+	"status":         true,
+	"createdByMSP":   true,
+	"externalRef":    true,
+	"creationSource": true,
+}
+
+// richAssetFields lists the asset JSON fields CreateAssetRich accepts
+var richAssetFields = map[string]bool{
+	"ID":          true,
+	"description": true,
+	"owner":       true,
+	"approvalOne": true,
+	"approvalTwo": true,
+	"registered":  true,
+	"metadata":    true,
+	"tags":        true,
+	"references":  true,
+	"amount":      true,
+}
+
+// batchOperationFields lists the fields each ExecuteBatch operation accepts besides "op"
+var batchOperationFields = map[string][]string{
+	"create":   {"ID", "description", "owner", "approvalOne", "approvalTwo", "registered"},
+	"update":   {"ID", "description", "owner", "approvalOne", "approvalTwo", "registered"},
+	"delete":   {"ID"},
+	"transfer": {"ID", "newOwner"},
+}
+
+// creationHistogramLayouts maps the granularities GetCreationHistogram accepts to the time layout of their buckets
+var creationHistogramLayouts = map[string]string{
+	"day":   "2006-01-02",
+	"month": "2006-01",
+	"year":  "2006",
+}
+
+// maxGraphNodes bounds the number of assets ExportAssetGraph includes
+const maxGraphNodes = 1000
+
+// extRefIndex is the composite key index mapping external references to asset IDs
+const extRefIndex = "extref~id"
+
+// lowerIDIndex names the composite key index mapping lower case asset IDs to the assets
+const lowerIDIndex = "lcid~id"
+
+// secondaryIndexes lists the composite key indexes kept for every asset
+var secondaryIndexes = []string{extRefIndex, lowerIDIndex}
+
+// reservedKeyPrefixes lists the key prefixes that belong to internal state and may never start an asset ID
+var reservedKeyPrefixes = []string{internalKeyPrefix, compositeKeyNamespace}
+
+// AssetState is a stage of the asset approval lifecycle
+type AssetState string
+
+// Lifecycle states an asset moves through on its way to registration
+const (
+	StateDraft       AssetState = "draft"
+	StateSubmitted   AssetState = "submitted"
+	StateApprovedOne AssetState = "approved_one"
+	StateApprovedTwo AssetState = "approved_two"
+	StateRegistered  AssetState = "registered"
+	StateRejected    AssetState = "rejected"
+
+	StatePendingAmendment AssetState = "pending_amendment"
+)
+
+// stateTransitions lists the states each lifecycle state may move to next
+var stateTransitions = map[AssetState][]AssetState{
+	StateDraft:       {StateSubmitted, StateRejected},
+	StateSubmitted:   {StateApprovedOne, StateRejected},
+	StateApprovedOne: {StateApprovedTwo, StateRejected},
+	StateApprovedTwo: {StateRegistered},
+	StateRegistered:  {StatePendingAmendment},
+	StateRejected:    {},
+
+	StatePendingAmendment: {StateApprovedOne, StateRegistered},
+}
+
+// amendmentTransitions replaces the entries of stateTransitions for assets carrying a pending amendment,
+// which return to registered instead of being rejected
+var amendmentTransitions = map[AssetState][]AssetState{
+	StateApprovedOne: {StateApprovedTwo, StateRegistered},
+}
+
 // SmartContract provides functions for managing an Asset
 type SmartContract struct {
 	contractapi.Contract
@@ -21,9 +200,72 @@ type Asset struct {
 	ID             string `json:"ID"`
 	Description    string `json:"description"`
 	Owner          string `json:"owner"`
+	Owners []string `json:"owners,omitempty"`
 	ApprovalOne int    `json:"approvalOne"`
 	ApprovalTwo int    `json:"approvalTwo"`
 	Registered int 		`json:"registered"`
+	CreatedByMSP string `json:"createdByMSP"`
+	CreatedAt time.Time `json:"createdAt"`
+	RegisteredAt time.Time `json:"registeredAt"`
+	RegisteredByMSPs [2]string `json:"registeredByMSPs"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Status AssetState `json:"status"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Tags []string `json:"tags,omitempty"`
+	Amount int `json:"amount"`
+	RequiredApprovers []string `json:"requiredApprovers,omitempty"`
+	SignedApprovers []string `json:"signedApprovers,omitempty"`
+	ExternalRef string `json:"externalRef,omitempty"`
+	ApprovalLog []ApprovalRecord `json:"approvalLog,omitempty"`
+	References []string `json:"references,omitempty"`
+	PendingAmendment *Amendment `json:"pendingAmendment,omitempty"`
+	SaleLog []SaleRecord `json:"saleLog,omitempty"`
+	InEscrow bool `json:"inEscrow,omitempty"`
+	Comments []Comment `json:"comments,omitempty"`
+	Documents []DocumentRef `json:"documents,omitempty"`
+	TransferAllowlist []string `json:"transferAllowlist,omitempty"`
+	ACL map[string]string `json:"acl,omitempty"`
+	Frozen bool `json:"frozen,omitempty"`
+	CreationSource string `json:"creationSource,omitempty"`
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// Amendment structure used for holding a proposed change to a registered asset until it is approved
+type Amendment struct {
+	Description string    `json:"description"`
+	ProposedBy  string    `json:"proposedBy"`
+	ProposedAt  time.Time `json:"proposedAt"`
+}
+
+// ApprovalRecord structure used for logging a single approval of an asset
+type ApprovalRecord struct {
+	ApproverMSP  string    `json:"approverMSP"`
+	ApprovalType string    `json:"approvalType"`
+	TxID         string    `json:"txID"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// SaleRecord structure used for logging a single sale of an asset
+type SaleRecord struct {
+	Price     int       `json:"price"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	TxID      string    `json:"txID"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Comment structure used for holding a note left on an asset
+type Comment struct {
+	AuthorMSP string    `json:"authorMSP"`
+	Text      string    `json:"text"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// DocumentRef structure used for anchoring an off-chain document to an asset by its hash
+type DocumentRef struct {
+	Name        string `json:"name"`
+	SHA256      string `json:"sha256"`
+	ContentType string `json:"contentType"`
 }
 
 // QueryResult structure used for handling result of query
@@ -32,214 +274,4756 @@ type QueryResult struct {
 	Record *Asset
 }
 
+// AssetSummary structure used for handling the identifying fields of an asset
+type AssetSummary struct {
+	ID     string     `json:"ID"`
+	Owner  string     `json:"owner"`
+	Status AssetState `json:"status"`
+}
+
+// ApprovalProgress structure used for reporting how far an asset is through the approval flow
+type ApprovalProgress struct {
+	ApprovalsGranted  int    `json:"approvalsGranted"`
+	ApprovalsRequired int    `json:"approvalsRequired"`
+	Percentage        int    `json:"percentage"`
+	Stage             string `json:"stage"`
+}
+
+// AssetView structure used for returning an asset along with values derived from it on read
+type AssetView struct {
+	Asset            *Asset           `json:"asset"`
+	ApprovalProgress ApprovalProgress `json:"approvalProgress"`
+	IsRegistered     bool             `json:"isRegistered"`
+	AgeSeconds       int64            `json:"ageSeconds"`
+	Status           string           `json:"status"`
+}
+
+// ApproverStatus structure used for reporting whether an expected approver has approved an asset
+type ApproverStatus struct {
+	Approver string `json:"approver"`
+	Signed   bool   `json:"signed"`
+}
+
+// EventRecord structure used for handling a create, update or delete event reconstructed from asset history
+type EventRecord struct {
+	Type      string    `json:"type"`
+	ID        string    `json:"ID"`
+	TxID      string    `json:"txID"`
+	Timestamp time.Time `json:"timestamp"`
+	Record    *Asset    `json:"record,omitempty"`
+}
+
+// LedgerInitializedEvent structure used as the payload of the LedgerInitialized event
+type LedgerInitializedEvent struct {
+	IDs []string `json:"IDs"`
+}
+
+// TransferEvent structure used as the payload of the AssetTransferred event
+type TransferEvent struct {
+	ID            string    `json:"ID"`
+	PreviousOwner string    `json:"previousOwner"`
+	NewOwner      string    `json:"newOwner"`
+	TxID          string    `json:"txID"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// EventEnvelope structure used for wrapping the payload of every chaincode event. Data holds the event
+// specific payload, such as the asset for AssetCreated or a TransferEvent for AssetTransferred.
+type EventEnvelope struct {
+	EventType     string          `json:"eventType"`
+	SchemaVersion int             `json:"schemaVersion"`
+	TxID          string          `json:"txID"`
+	Timestamp     time.Time       `json:"timestamp"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// PagedQueryResult structure used for handling one page of a rich query result
+type PagedQueryResult struct {
+	Records      []QueryResult `json:"records"`
+	Bookmark     string        `json:"bookmark"`
+	FetchedCount int32         `json:"fetchedCount"`
+}
+
+// BulkReadResult structure used for handling the assets a bulk read found along with the IDs it did not find
+type BulkReadResult struct {
+	Found    []QueryResult `json:"found"`
+	NotFound []string      `json:"notFound"`
+}
+
+// LimitedQueryResult structure used for handling the first assets of a query cut off at a limit
+type LimitedQueryResult struct {
+	Records []QueryResult `json:"records"`
+	HasMore bool          `json:"hasMore"`
+}
+
+// SimResult structure used for handling the outcome of a simulated transaction and what would block it
+type SimResult struct {
+	WouldSucceed bool     `json:"wouldSucceed"`
+	Reasons      []string `json:"reasons"`
+}
+
+// ChaincodeInfo structure used for handling the version of the installed chaincode and the features it has enabled
+type ChaincodeInfo struct {
+	Version            string   `json:"version"`
+	AssetSchemaVersion int      `json:"assetSchemaVersion"`
+	EventSchemaVersion int      `json:"eventSchemaVersion"`
+	EnabledFeatures    []string `json:"enabledFeatures"`
+}
+
+// LedgerSummary structure used for handling the totals ProcessAllAssets folds the world state into
+type LedgerSummary struct {
+	TotalAssets int            `json:"totalAssets"`
+	ByStatus    map[string]int `json:"byStatus"`
+	Batches     int            `json:"batches"`
+}
+
+// AmountStats structure used for reporting aggregates over the amounts of all assets
+type AmountStats struct {
+	Count   int     `json:"count"`
+	Min     int     `json:"min"`
+	Max     int     `json:"max"`
+	Sum     int     `json:"sum"`
+	Average float64 `json:"average"`
+}
+
+// IndexEntry structure used for handling a single secondary index entry mapping a value to an asset
+type IndexEntry struct {
+	Index string `json:"index"`
+	Value string `json:"value"`
+	ID    string `json:"ID"`
+}
+
+// IndexReport structure used for reporting the secondary index entries that do not match a live asset
+type IndexReport struct {
+	Checked int          `json:"checked"`
+	Orphans []IndexEntry `json:"orphans"`
+}
+
+// BatchOperation structure used for handling a single operation of a batch
+type BatchOperation struct {
+	Op          string `json:"op"`
+	ID          string `json:"ID"`
+	Description string `json:"description"`
+	Owner       string `json:"owner"`
+	NewOwner    string `json:"newOwner"`
+	ApprovalOne int    `json:"approvalOne"`
+	ApprovalTwo int    `json:"approvalTwo"`
+	Registered  int    `json:"registered"`
+}
+
+// BatchOperationResult structure used for reporting the outcome of a single operation of a batch
+type BatchOperationResult struct {
+	Index int    `json:"index"`
+	Op    string `json:"op"`
+	ID    string `json:"ID"`
+}
+
+// BatchResult structure used as the result and event payload of ExecuteBatch
+type BatchResult struct {
+	TxID    string                 `json:"txID"`
+	Results []BatchOperationResult `json:"results"`
+}
+
+// FeedPage structure used for handling one page of the registered asset feed
+type FeedPage struct {
+	Records      []QueryResult `json:"records"`
+	Bookmark     string        `json:"bookmark"`
+	FetchedCount int32         `json:"fetchedCount"`
+}
+
+// AssetGraph structure used for exporting the references between assets
+type AssetGraph struct {
+	Nodes     []string    `json:"nodes"`
+	Edges     []GraphEdge `json:"edges"`
+	Truncated bool        `json:"truncated"`
+}
+
+// GraphEdge structure used for handling a reference from one asset to another
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// AssetHistory structure used for handling a single entry of an asset's history
+type AssetHistory struct {
+	TxID      string    `json:"txID"`
+	Timestamp time.Time `json:"timestamp"`
+	IsDelete  bool      `json:"isDelete"`
+	Record    *Asset    `json:"record,omitempty"`
+}
+
+// TimelineEvent structure used for handling a single event in the timeline of an asset
+type TimelineEvent struct {
+	Type      string          `json:"type"`
+	TxID      string          `json:"txID"`
+	Timestamp time.Time       `json:"timestamp"`
+	Record    *Asset          `json:"record,omitempty"`
+	Approval  *ApprovalRecord `json:"approval,omitempty"`
+}
+
+// OwnershipDuration structure used for handling a period in which an asset was held by a single owner
+type OwnershipDuration struct {
+	Owner           string    `json:"owner"`
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	DurationSeconds int64     `json:"durationSeconds"`
+	Current         bool      `json:"current"`
+}
+
+// AssetHistoryPage structure used for handling a slice of an asset's history along with its total size
+type AssetHistoryPage struct {
+	Records []AssetHistory `json:"records"`
+	Total   int            `json:"total"`
+}
+
 // InitLedger adds a base set of assets to the ledger
+// It emits a single LedgerInitialized event listing the IDs of the sample assets.
 func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
 	assets := []Asset{
 		Asset{ID: "asset1", Description: "myAsset", Owner: "Org1", ApprovalOne: 0, ApprovalTwo: 0, Registered: 0 },
 		Asset{ID: "asset2", Description: "anotherAsset", Owner: "Org1", ApprovalOne: 0, ApprovalTwo: 0, Registered: 0 },
 	}
 
+	event := LedgerInitializedEvent{IDs: []string{}}
 	for _, asset := range assets {
-		assetJSON, err := json.Marshal(asset)
+		err := prepareNewAsset(ctx, &asset)
 		if err != nil {
 			return err
 		}
 
-		err = ctx.GetStub().PutState(asset.ID, assetJSON)
+		err = putAsset(ctx, &asset)
 		if err != nil {
-			return fmt.Errorf("Failed to put to world state. %s", err.Error())
+			return err
 		}
+		event.IDs = append(event.IDs, asset.ID)
 	}
 
-	return nil
+	// the sample assets may overwrite existing ones, so drop the counter and let the next count rebuild it
+	err := resetAssetCount(ctx)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, "LedgerInitialized", event)
 }
 
 // CreateAsset issues a new asset to the world state with given details.
+// An empty owner defaults to the caller's identity; only callers with the admin attribute may create
+// assets on behalf of another owner.
 func (s *SmartContract) CreateAsset(ctx contractapi.TransactionContextInterface, id, description, owner string, approvalOne, approvalTwo, registered int) error {
-	exists, err := s.AssetExists(ctx, id)
+	return s.issueAsset(ctx, CreationSourceManual, id, description, owner, approvalOne, approvalTwo, registered)
+}
+
+// issueAsset validates and creates an asset the way CreateAsset does, recording source as its creation source.
+func (s *SmartContract) issueAsset(ctx contractapi.TransactionContextInterface, source, id, description, owner string, approvalOne, approvalTwo, registered int) error {
+	owner, err := resolveNewOwner(ctx, owner)
 	if err != nil {
 		return err
 	}
-	if exists {
-		return fmt.Errorf("The asset %s already exists", id)
-	}
-
-	asset := Asset{
-		ID:              id,
-		Description:     description,
-		Owner:           owner,
-		ApprovalOne:     approvalOne,
-		ApprovalTwo:     approvalTwo,		
-		Registered:      registered,
-	}
 
-	assetJSON, err := json.Marshal(asset)
+	err = validateInitialFlags(&Asset{ApprovalOne: approvalOne, ApprovalTwo: approvalTwo, Registered: registered})
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState(id, assetJSON)
+	_, err = s.createAsset(ctx, source, id, description, owner, approvalOne, approvalTwo, registered)
+	return err
 }
 
-// ReadAsset returns the asset stored in the world state with given id.
-func (s *SmartContract) ReadAsset(ctx contractapi.TransactionContextInterface, id string) (*Asset, error) {
-	assetJSON, err := ctx.GetStub().GetState(id)
+// CreateAssetRich issues a new asset described by assetJSON, which may set its tags, metadata, references
+// and amount along with the fields CreateAsset takes, and validates all of them the way the dedicated
+// functions would. Fields the chaincode manages itself, such as its status or approval log, are rejected.
+func (s *SmartContract) CreateAssetRich(ctx contractapi.TransactionContextInterface, assetJSON string) error {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal([]byte(assetJSON), &fields)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+		return fmt.Errorf("Failed to parse the asset. %s", err.Error())
 	}
-	if assetJSON == nil {
-		return nil, fmt.Errorf("The asset %s does not exist", id)
+	for field := range fields {
+		if !richAssetFields[field] {
+			return fmt.Errorf("The field %s cannot be set when creating an asset", field)
+		}
 	}
 
-	asset := new(Asset)
-	err = json.Unmarshal(assetJSON, asset)
+	var asset Asset
+	err = json.Unmarshal([]byte(assetJSON), &asset)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("Failed to parse the asset. %s", err.Error())
 	}
 
-	return asset, nil
-}
-
-// UpdateAsset updates an existing asset in the world state with provided parameters.
-func (s *SmartContract) UpdateAsset(ctx contractapi.TransactionContextInterface, id, description, owner string, approvalOne, approvalTwo, registered int) error {
-	exists, err := s.AssetExists(ctx, id)
+	asset.Owner, err = resolveNewOwner(ctx, asset.Owner)
 	if err != nil {
 		return err
 	}
-	if !exists {
-		return fmt.Errorf("The asset %s does not exist", id)
-	}
 
-	// overwritting original asset with new asset
+	err = validateInitialFlags(&asset)
+	if err != nil {
+		return err
+	}
 
-	asset := Asset{
-		ID:              id,
-		Description:     description,
-		Owner:           owner,
-		ApprovalOne:     approvalOne,
-		ApprovalTwo:     approvalTwo,		
-		Registered:      registered,
+	err = validateTags(asset.Tags)
+	if err != nil {
+		return err
 	}
 
-	assetJSON, err := json.Marshal(asset)
+	err = validateAmount(asset.Amount)
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState(id, assetJSON)
-}
+	for key, value := range asset.Metadata {
+		err = validateMetadataKey(key)
+		if err != nil {
+			return err
+		}
+		if value == "" {
+			return fmt.Errorf("Metadata entry %s must not be empty", key)
+		}
+	}
 
-// DeleteAsset deletes an given asset from the world state.
-func (s *SmartContract) DeleteAsset(ctx contractapi.TransactionContextInterface, id string) error {
-	exists, err := s.AssetExists(ctx, id)
+	err = s.validateReferences(ctx, asset.ID, asset.References)
 	if err != nil {
 		return err
 	}
-	if !exists {
-		return fmt.Errorf("The asset %s does not exist", id)
-	}
 
-	return ctx.GetStub().DelState(id)
+	return s.writeNewAsset(ctx, &asset)
 }
 
-// AssetExists returns true when asset with given ID exists in world state
-func (s *SmartContract) AssetExists(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
-	assetJSON, err := ctx.GetStub().GetState(id)
+// resolveNewOwner returns the owner of an asset the caller creates. An empty owner defaults to the caller,
+// and only callers with the admin attribute may name another owner.
+func resolveNewOwner(ctx contractapi.TransactionContextInterface, owner string) (string, error) {
+	callerID, err := getClientMSPID(ctx)
 	if err != nil {
-		return false, fmt.Errorf("Failed to read from world state. %s", err.Error())
+		return "", err
 	}
 
-	return assetJSON != nil, nil
+	if owner == "" {
+		return callerID, nil
+	}
+	if owner != callerID {
+		admin, err := hasAdminAttribute(ctx)
+		if err != nil {
+			return "", err
+		}
+		if !admin {
+			return "", fmt.Errorf("Client %s is not authorized to create an asset owned by %s", callerID, owner)
+		}
+	}
+
+	return owner, nil
 }
 
-// TransferAsset updates the owner field of asset with given id in world state.
-func (s *SmartContract) TransferAsset(ctx contractapi.TransactionContextInterface, id string, newOwner string) error {
-	asset, err := s.ReadAsset(ctx, id)
-	if err != nil {
-		return err
+// validateInitialFlags returns an error unless the approval flags of a new asset are reachable from draft.
+func validateInitialFlags(asset *Asset) error {
+	// new assets start as drafts, so the given flags may at most imply a single step beyond that
+	initial := stateFromFlags(asset, StateDraft)
+	if initial != StateDraft {
+		return validateTransition(StateDraft, initial)
 	}
 
-	asset.Owner = newOwner
+	return nil
+}
 
-	assetJSON, err := json.Marshal(asset)
-	if err != nil {
-		return err
+// validateAmount returns an error for negative amounts.
+func validateAmount(amount int) error {
+	if amount < 0 {
+		return fmt.Errorf("The amount %d must not be negative", amount)
 	}
 
-	return ctx.GetStub().PutState(id, assetJSON)
+	return nil
 }
 
-// Change ApprovalOne to 1 from 0
-func (s * SmartContract) ApproveRequestOne(ctx contractapi.TransactionContextInterface, id string) error{
-	asset, err := s.ReadAsset(ctx, id)
-	if err != nil {
-		return err
+// validateTags returns an error for empty or repeated tags.
+func validateTags(tags []string) error {
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		if strings.TrimSpace(tag) == "" {
+			return fmt.Errorf("Tags must not be empty")
+		}
+		if seen[tag] {
+			return fmt.Errorf("The tag %s is repeated", tag)
+		}
+		seen[tag] = true
 	}
 
-	asset.ApprovalOne = 1
-	assetJSON, err := json.Marshal(asset)
+	return nil
+}
+
+// ExecuteBatch runs the operations in opsJSON, a JSON array of create, update, delete and transfer operations
+// taking the same arguments as CreateAsset, UpdateAsset, DeleteAsset and TransferAsset, in order within
+// the one transaction. The first failing operation fails the whole transaction, so either every operation
+// is committed or none is. Reads in a transaction do not see its own writes, so each asset may only be
+// touched by one operation of a batch. It emits a BatchExecuted event carrying the result.
+func (s *SmartContract) ExecuteBatch(ctx contractapi.TransactionContextInterface, opsJSON string) (*BatchResult, error) {
+	operations, err := parseBatchOperations(opsJSON)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return ctx.GetStub().PutState(id, assetJSON)
+	result := &BatchResult{TxID: ctx.GetStub().GetTxID(), Results: []BatchOperationResult{}}
 
- }
+	for i, operation := range operations {
+		switch operation.Op {
+		case "create":
+			err = s.CreateAsset(ctx, operation.ID, operation.Description, operation.Owner, operation.ApprovalOne, operation.ApprovalTwo, operation.Registered)
+		case "update":
+			err = s.UpdateAsset(ctx, operation.ID, operation.Description, operation.Owner, operation.ApprovalOne, operation.ApprovalTwo, operation.Registered)
+		case "delete":
+			err = s.DeleteAsset(ctx, operation.ID)
+		case "transfer":
+			err = s.TransferAsset(ctx, operation.ID, operation.NewOwner)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Operation %d (%s %s) failed, rejecting the batch. %s", i, operation.Op, operation.ID, err.Error())
+		}
 
+		result.Results = append(result.Results, BatchOperationResult{Index: i, Op: operation.Op, ID: operation.ID})
+	}
 
-// Change ApprovalTwo to 1 from 0
-func (s * SmartContract) ApproveRequestTwo(ctx contractapi.TransactionContextInterface, id string) error{
-	asset, err := s.ReadAsset(ctx, id)
+	// every create and delete adjusted the same counter value, so drop the counter and let the next count rebuild it
+	err = resetAssetCount(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	asset.ApprovalTwo = 1
-	asset.Registered = 1
-	assetJSON, err := json.Marshal(asset)
+	// the batch event replaces the events set by the individual operations
+	err = emitEvent(ctx, "BatchExecuted", result)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return ctx.GetStub().PutState(id, assetJSON)
-
- }
-
-// GetAllAssets returns all assets found in world state
-func (s *SmartContract) GetAllAssets(ctx contractapi.TransactionContextInterface) ([]QueryResult, error) {
-	// range query with empty string for startKey and endKey does an open-ended query of all assets in the chaincode namespace.
-	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	return result, nil
+}
 
+// parseBatchOperations decodes and validates the operations of a batch.
+func parseBatchOperations(opsJSON string) ([]BatchOperation, error) {
+	var rawOperations []map[string]json.RawMessage
+	err := json.Unmarshal([]byte(opsJSON), &rawOperations)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("The operations must be a JSON array of objects. %s", err.Error())
+	}
+	if len(rawOperations) == 0 {
+		return nil, fmt.Errorf("A batch needs at least one operation")
+	}
+	if len(rawOperations) > maxBatchOperations {
+		return nil, fmt.Errorf("A batch holds at most %d operations, got %d", maxBatchOperations, len(rawOperations))
 	}
-	defer resultsIterator.Close()
-
-	results := []QueryResult{}
 
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
+	operations := []BatchOperation{}
+	touched := make(map[string]bool)
 
+	for i, rawOperation := range rawOperations {
+		var operation BatchOperation
+		operationJSON, err := json.Marshal(rawOperation)
 		if err != nil {
 			return nil, err
 		}
-
-		asset := new(Asset)
-		err = json.Unmarshal(queryResponse.Value, asset)
+		err = json.Unmarshal(operationJSON, &operation)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("Failed to parse operation %d. %s", i, err.Error())
 		}
 
-		queryResult := QueryResult{Key: queryResponse.Key, Record: asset}
-		results = append(results, queryResult)
+		fields, known := batchOperationFields[operation.Op]
+		if !known {
+			return nil, fmt.Errorf("Operation %d has unknown type %q. Expected create, update, delete or transfer", i, operation.Op)
+		}
+		for field := range rawOperation {
+			if field != "op" && !containsString(fields, field) {
+				return nil, fmt.Errorf("Operation %d (%s) does not take the field %s", i, operation.Op, field)
+			}
+		}
+
+		if operation.ID == "" {
+			return nil, fmt.Errorf("Operation %d (%s) needs an ID", i, operation.Op)
+		}
+		if operation.Op == "transfer" && operation.NewOwner == "" {
+			return nil, fmt.Errorf("Operation %d (transfer) needs a newOwner", i)
+		}
+		if touched[operation.ID] {
+			return nil, fmt.Errorf("Operation %d touches asset %s again; each asset may only appear once in a batch", i, operation.ID)
+		}
+		touched[operation.ID] = true
+
+		operations = append(operations, operation)
 	}
 
-	return results, nil
+	return operations, nil
 }
 
-func main() {
+// CreateAssetAutoID issues a new asset with a generated ID and returns that ID.
+// The ID follows the strategy configured with SetIDStrategy, defaulting to one derived from the transaction ID.
+func (s *SmartContract) CreateAssetAutoID(ctx contractapi.TransactionContextInterface, description, owner string) (string, error) {
+	id, err := nextAssetID(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	err = s.issueAsset(ctx, CreationSourceAuto, id, description, owner, 0, 0, 0)
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// CreateContentAddressedAsset issues a new asset whose ID is derived from its content and returns that ID.
+// The ID is the SHA-256 of the normalized description and owner, so creating the same content twice fails
+// as a duplicate. Two different assets can only share an ID through a SHA-256 collision, which is not a
+// practical concern, but assets that differ only in case or whitespace are deliberately treated as the same.
+func (s *SmartContract) CreateContentAddressedAsset(ctx contractapi.TransactionContextInterface, description, owner string) (string, error) {
+	if owner == "" {
+		callerID, err := getClientMSPID(ctx)
+		if err != nil {
+			return "", err
+		}
+		owner = callerID
+	}
+
+	id := contentAddress(description, owner)
+
+	exists, err := s.AssetExists(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return "", fmt.Errorf("An asset with the same content already exists as %s", id)
+	}
+
+	err = s.issueAsset(ctx, CreationSourceAuto, id, description, owner, 0, 0, 0)
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// contentAddress derives the ID of a content addressed asset. The description is compared case-insensitively
+// with runs of whitespace collapsed, and the owner with surrounding whitespace ignored.
+func contentAddress(description, owner string) string {
+	normalizedDescription := strings.ToLower(strings.Join(strings.Fields(description), " "))
+	normalizedOwner := strings.TrimSpace(owner)
+
+	// the lengths keep the boundary between the fields unambiguous
+	content := fmt.Sprintf("%d:%s%d:%s", len(normalizedDescription), normalizedDescription, len(normalizedOwner), normalizedOwner)
+	hash := sha256.Sum256([]byte(content))
+
+	return "asset-" + hex.EncodeToString(hash[:])
+}
+
+// SetIDStrategy selects how CreateAssetAutoID generates IDs, either "txhash" or "sequential".
+// Only admins may change it.
+func (s *SmartContract) SetIDStrategy(ctx contractapi.TransactionContextInterface, strategy string) error {
+	if strategy != IDStrategyTxHash && strategy != IDStrategySequential {
+		return fmt.Errorf("Unknown ID strategy %s. Expected %s or %s", strategy, IDStrategyTxHash, IDStrategySequential)
+	}
+
+	return setAdminConfig(ctx, idStrategyConfig, strategy)
+}
+
+// nextAssetID generates an asset ID according to the configured ID strategy.
+//
+// The sequential counter is read and written back within the transaction. Fabric's read-set validation
+// rejects one of any two transactions that incremented the same counter value, so IDs are never reused,
+// but since reads do not see pending writes, a transaction can only generate a single sequential ID.
+// Counter values whose ID is already used, for instance by an asset created manually, are skipped.
+func nextAssetID(ctx contractapi.TransactionContextInterface) (string, error) {
+	strategy, err := getConfig(ctx, idStrategyConfig)
+	if err != nil {
+		return "", err
+	}
+
+	if strategy != IDStrategySequential {
+		return "asset-" + ctx.GetStub().GetTxID(), nil
+	}
+
+	counter, _, err := getCounter(ctx, assetIDCounter)
+	if err != nil {
+		return "", err
+	}
+
+	var id string
+	for {
+		counter++
+		id = fmt.Sprintf("asset-%d", counter)
+
+		key, err := assetKey(ctx, id)
+		if err != nil {
+			return "", err
+		}
+		existing, err := ctx.GetStub().GetState(key)
+		if err != nil {
+			return "", fmt.Errorf("Failed to read from world state. %s", err.Error())
+		}
+		if existing == nil {
+			break
+		}
+	}
+
+	err = putCounter(ctx, assetIDCounter, counter)
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// getCounter reads the internal counter with given name. It reports false when the counter was never written.
+func getCounter(ctx contractapi.TransactionContextInterface, name string) (int, bool, error) {
+	counterJSON, err := ctx.GetStub().GetState(counterKeyPrefix + name)
+	if err != nil {
+		return 0, false, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+	if counterJSON == nil {
+		return 0, false, nil
+	}
+
+	var counter int
+	err = json.Unmarshal(counterJSON, &counter)
+	if err != nil {
+		return 0, false, err
+	}
+
+	return counter, true, nil
+}
+
+// putCounter writes the internal counter with given name.
+func putCounter(ctx contractapi.TransactionContextInterface, name string, counter int) error {
+	err := requireWritable(ctx)
+	if err != nil {
+		return err
+	}
+
+	counterJSON, err := json.Marshal(counter)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(counterKeyPrefix+name, counterJSON)
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// CountAssets returns the number of assets in world state. It reads the total assets counter and
+// falls back to counting with a full scan when the counter has not been written yet.
+func (s *SmartContract) CountAssets(ctx contractapi.TransactionContextInterface) (int, error) {
+	counter, err := assetCountCounter(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count, found, err := getCounter(ctx, counter)
+	if err != nil {
+		return 0, err
+	}
+	if found {
+		return count, nil
+	}
+
+	assets, err := getEveryAsset(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(assets), nil
+}
+
+// adjustAssetCount adds delta to the total assets counter, seeding a missing counter from a full scan.
+// It must run before the asset itself is written or deleted so that the seeding scan sees the old state.
+//
+// Like the sequential ID counter, concurrent transactions that adjust the count conflict on the counter
+// key and all but one are rejected at validation, so the count never drifts, but a transaction can only
+// adjust it once.
+func adjustAssetCount(ctx contractapi.TransactionContextInterface, delta int) error {
+	counter, err := assetCountCounter(ctx)
+	if err != nil {
+		return err
+	}
+
+	count, found, err := getCounter(ctx, counter)
+	if err != nil {
+		return err
+	}
+	if !found {
+		assets, err := getEveryAsset(ctx)
+		if err != nil {
+			return err
+		}
+		count = len(assets)
+	}
+
+	count += delta
+	if count < 0 {
+		return fmt.Errorf("The total asset count cannot become negative")
+	}
+
+	return putCounter(ctx, counter, count)
+}
+
+// resetAssetCount drops the total assets counter so that the next count rebuilds it with a full scan.
+func resetAssetCount(ctx contractapi.TransactionContextInterface) error {
+	err := requireWritable(ctx)
+	if err != nil {
+		return err
+	}
+
+	counter, err := assetCountCounter(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().DelState(counterKeyPrefix + counter)
+	if err != nil {
+		return fmt.Errorf("Failed to delete from world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// assetCountCounter names the counter tracking the number of assets the caller can see, one per tenant.
+func assetCountCounter(ctx contractapi.TransactionContextInterface) (string, error) {
+	tenant, err := getTenant(ctx)
+	if err != nil {
+		return "", err
+	}
+	if tenant == "" {
+		return totalAssetsCounter, nil
+	}
+
+	return totalAssetsCounter + "~" + tenant, nil
+}
+
+// RegisterAssetDirectly creates an asset that is already approved by both parties and registered.
+// Only clients of the admin MSP may skip the approval flow this way.
+func (s *SmartContract) RegisterAssetDirectly(ctx contractapi.TransactionContextInterface, id, description, owner string) error {
+	err := requireAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	asset, err := s.createAsset(ctx, CreationSourceManual, id, description, owner, 1, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, "AssetRegistered", asset)
+}
+
+// createAsset writes a new asset with given details to the world state, increments the total assets counter and returns it.
+func (s *SmartContract) createAsset(ctx contractapi.TransactionContextInterface, source, id, description, owner string, approvalOne, approvalTwo, registered int) (*Asset, error) {
+	asset := Asset{
+		ID:              id,
+		Description:     description,
+		Owner:           owner,
+		ApprovalOne:     approvalOne,
+		ApprovalTwo:     approvalTwo,		
+		Registered:      registered,
+		CreationSource:  source,
+	}
+
+	err := s.writeNewAsset(ctx, &asset)
+	if err != nil {
+		return nil, err
+	}
+
+	return &asset, nil
+}
+
+// prepareNewAsset records the provenance, creation source and initial state of asset before it is first written.
+func prepareNewAsset(ctx contractapi.TransactionContextInterface, asset *Asset) error {
+	var err error
+	asset.CreatedByMSP, err = getClientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+
+	asset.CreatedAt, err = getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	if asset.CreationSource == "" {
+		asset.CreationSource = CreationSourceManual
+	}
+
+	migrateOwners(asset)
+	asset.Status = stateFromFlags(asset, StateDraft)
+
+	return nil
+}
+
+// writeNewAsset records the provenance and initial state of asset, writes it to the world state unless
+// an asset with its ID already exists, and increments the total assets counter.
+func (s *SmartContract) writeNewAsset(ctx contractapi.TransactionContextInterface, asset *Asset) error {
+	exists, err := s.AssetExists(ctx, asset.ID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("The asset %s already exists", asset.ID)
+	}
+
+	err = prepareNewAsset(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	err = adjustAssetCount(ctx, 1)
+	if err != nil {
+		return err
+	}
+
+	err = putAsset(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, "AssetCreated", asset)
+}
+
+// SetOwnerMSPAllowlist restricts asset owners to the given MSP IDs. Only admins may change it.
+// An empty list turns the restriction off and lets any owner be set.
+func (s *SmartContract) SetOwnerMSPAllowlist(ctx contractapi.TransactionContextInterface, mspIDs []string) error {
+	allowlistJSON, err := json.Marshal(mspIDs)
+	if err != nil {
+		return err
+	}
+
+	return setAdminConfig(ctx, ownerMSPAllowlistConfig, string(allowlistJSON))
+}
+
+// validateOwnerMSP returns an error when the owner MSP allowlist is set and owner is not on it.
+func validateOwnerMSP(ctx contractapi.TransactionContextInterface, owner string) error {
+	allowlistJSON, err := getConfig(ctx, ownerMSPAllowlistConfig)
+	if err != nil {
+		return err
+	}
+	if allowlistJSON == "" {
+		return nil
+	}
+
+	var allowlist []string
+	err = json.Unmarshal([]byte(allowlistJSON), &allowlist)
+	if err != nil {
+		return err
+	}
+
+	if len(allowlist) > 0 && !containsString(allowlist, owner) {
+		return fmt.Errorf("The owner %s is not one of the allowed MSPs %s", owner, strings.Join(allowlist, ", "))
+	}
+
+	return nil
+}
+
+// ReadAsset returns the asset stored in the world state with given id. In ACL mode the caller needs read access to it.
+func (s *SmartContract) ReadAsset(ctx contractapi.TransactionContextInterface, id string) (*Asset, error) {
+	asset, err := readStoredAsset(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = requireAccess(ctx, asset, PermissionRead)
+	if err != nil {
+		return nil, err
+	}
+
+	return asset, nil
+}
+
+// readStoredAsset returns the asset stored in the world state with given id after verifying its checksum,
+// without checking the caller's access to it.
+func readStoredAsset(ctx contractapi.TransactionContextInterface, id string) (*Asset, error) {
+	if isReservedKey(id) {
+		return nil, fmt.Errorf("The asset %s does not exist", id)
+	}
+
+	key, err := assetKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	assetJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+	if assetJSON == nil {
+		return nil, fmt.Errorf("The asset %s does not exist", id)
+	}
+
+	asset := new(Asset)
+	err = json.Unmarshal(assetJSON, asset)
+	if err != nil {
+		return nil, err
+	}
+
+	// records written before checksums were introduced carry none and are accepted as they are
+	if asset.Checksum == "" && !predatesChecksums(asset) {
+		return nil, fmt.Errorf("The asset %s is corrupted: its checksum is missing", id)
+	}
+	if asset.Checksum != "" {
+		checksum, err := assetChecksum(assetJSON)
+		if err != nil {
+			return nil, err
+		}
+		if checksum != asset.Checksum {
+			return nil, fmt.Errorf("The asset %s is corrupted: stored checksum does not match its contents", id)
+		}
+	}
+	migrateOwners(asset)
+
+	return asset, nil
+}
+
+// ReadAssetLight returns the ID, owner and lifecycle state of the asset with given id.
+// Only those fields are decoded, skipping the metadata, owners and logs a full record carries, which makes
+// it cheaper than ReadAsset for dashboards polling many assets. In exchange the checksum is not verified,
+// so use ReadAsset wherever a corrupted record must be detected.
+func (s *SmartContract) ReadAssetLight(ctx contractapi.TransactionContextInterface, id string) (*AssetSummary, error) {
+	if isReservedKey(id) {
+		return nil, fmt.Errorf("The asset %s does not exist", id)
+	}
+
+	key, err := assetKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	assetJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+	if assetJSON == nil {
+		return nil, fmt.Errorf("The asset %s does not exist", id)
+	}
+
+	// the approval flags are decoded as well, to derive the state of records written before it was stored
+	var fields struct {
+		ID          string     `json:"ID"`
+		Owner       string     `json:"owner"`
+		ApprovalOne int        `json:"approvalOne"`
+		ApprovalTwo int        `json:"approvalTwo"`
+		Registered  int        `json:"registered"`
+		Status      AssetState `json:"status"`
+	}
+	err = json.Unmarshal(assetJSON, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	state := currentState(&Asset{ApprovalOne: fields.ApprovalOne, ApprovalTwo: fields.ApprovalTwo, Registered: fields.Registered, Status: fields.Status})
+
+	return &AssetSummary{ID: fields.ID, Owner: fields.Owner, Status: state}, nil
+}
+
+// UpdateAsset updates an existing asset in the world state with provided parameters.
+// Registered assets are immutable here unless the caller carries the admin attribute. The approval flags
+// may only change here for callers carrying the admin attribute; everyone else passes them unchanged and
+// moves the asset through ApproveRequestOne and ApproveRequestTwo, which enforce the required approvers
+// and record the approval log.
+func (s *SmartContract) UpdateAsset(ctx contractapi.TransactionContextInterface, id, description, owner string, approvalOne, approvalTwo, registered int) error {
+	original, err := s.readAssetForWrite(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if original.Registered == 1 {
+		admin, err := hasAdminAttribute(ctx)
+		if err != nil {
+			return err
+		}
+		if !admin {
+			return fmt.Errorf("The asset %s is registered and can no longer be updated; use TransferAsset to change its owner or an amendment to change its details", id)
+		}
+	}
+
+	if approvalOne != original.ApprovalOne || approvalTwo != original.ApprovalTwo || registered != original.Registered {
+		admin, err := hasAdminAttribute(ctx)
+		if err != nil {
+			return err
+		}
+		if !admin {
+			return fmt.Errorf("The approvals of asset %s can only change through ApproveRequestOne and ApproveRequestTwo", id)
+		}
+	}
+
+	// overwritting the updatable fields of the original asset, keeping the fields that are
+	// managed by dedicated functions such as provenance, metadata and approvers
+
+	asset := *original
+	asset.Description = description
+	asset.Owner = owner
+	asset.ApprovalOne = approvalOne
+	asset.ApprovalTwo = approvalTwo
+	asset.Registered = registered
+
+	current := currentState(original)
+	asset.Status = stateFromFlags(&asset, current)
+	if asset.Status != current {
+		err = validateTransition(current, asset.Status)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = putAssetIfChanged(ctx, &asset)
+	return err
+}
+
+// UpdateAssets applies UpdateAsset to every asset in assetsJSON, a JSON array of assets, and returns how many
+// it updated. Only the fields UpdateAsset changes are taken from each entry; the rest are managed by dedicated
+// functions and ignored, so assets read back from the ledger can be edited and sent as they are. Every asset
+// must already exist and appear once. Nothing is written when any entry fails, and all missing IDs are reported.
+func (s *SmartContract) UpdateAssets(ctx contractapi.TransactionContextInterface, assetsJSON string) (int, error) {
+	var assets []Asset
+	err := json.Unmarshal([]byte(assetsJSON), &assets)
+	if err != nil {
+		return 0, fmt.Errorf("Assets must be a JSON array of assets. %s", err.Error())
+	}
+	if len(assets) > maxBulkUpdateAssets {
+		return 0, fmt.Errorf("At most %d assets can be updated at once, got %d", maxBulkUpdateAssets, len(assets))
+	}
+
+	missing := []string{}
+	seen := make(map[string]bool)
+
+	for i, asset := range assets {
+		if asset.ID == "" {
+			return 0, fmt.Errorf("Asset %d has no ID", i)
+		}
+		// reads do not see pending writes, so a second update of the same asset would undo the first
+		if seen[asset.ID] {
+			return 0, fmt.Errorf("The asset %s appears more than once; each asset may only be updated once per call", asset.ID)
+		}
+		seen[asset.ID] = true
+
+		exists, err := s.AssetExists(ctx, asset.ID)
+		if err != nil {
+			return 0, err
+		}
+		if !exists {
+			missing = append(missing, asset.ID)
+		}
+	}
+	if len(missing) > 0 {
+		return 0, fmt.Errorf("The assets %s do not exist", strings.Join(missing, ", "))
+	}
+
+	for _, asset := range assets {
+		err = s.UpdateAsset(ctx, asset.ID, asset.Description, asset.Owner, asset.ApprovalOne, asset.ApprovalTwo, asset.Registered)
+		if err != nil {
+			return 0, fmt.Errorf("Failed to update asset %s. %s", asset.ID, err.Error())
+		}
+	}
+
+	return len(assets), nil
+}
+
+// DeleteAsset deletes an given asset from the world state, along with its secondary index entries,
+// and decrements the total assets counter.
+func (s *SmartContract) DeleteAsset(ctx contractapi.TransactionContextInterface, id string) error {
+	err := requireWritable(ctx)
+	if err != nil {
+		return err
+	}
+
+	asset, err := s.readAssetForWrite(ctx, id)
+	if err != nil {
+		return err
+	}
+	if asset.Frozen {
+		return fmt.Errorf("The asset %s is frozen and cannot change until it is unfrozen", id)
+	}
+
+	// moving the index entries to an empty asset removes them
+	err = updateIndexes(ctx, asset, &Asset{})
+	if err != nil {
+		return err
+	}
+
+	err = adjustAssetCount(ctx, -1)
+	if err != nil {
+		return err
+	}
+
+	key, err := assetKey(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().DelState(key)
+}
+
+// ReadAssetsDetailed reads the assets whose IDs idsJSON lists as a JSON array and returns those it found, in
+// the order given, along with the IDs of those that do not exist. Missing assets never fail the call, but any
+// other failure to read one does. IDs listed twice are read once.
+func (s *SmartContract) ReadAssetsDetailed(ctx contractapi.TransactionContextInterface, idsJSON string) (*BulkReadResult, error) {
+	var ids []string
+	err := json.Unmarshal([]byte(idsJSON), &ids)
+	if err != nil {
+		return nil, fmt.Errorf("IDs must be a JSON array of strings. %s", err.Error())
+	}
+	if len(ids) > maxBulkReadIDs {
+		return nil, fmt.Errorf("At most %d assets can be read at once, got %d", maxBulkReadIDs, len(ids))
+	}
+
+	result := &BulkReadResult{Found: []QueryResult{}, NotFound: []string{}}
+	seen := make(map[string]bool)
+
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		exists, err := s.AssetExists(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			result.NotFound = append(result.NotFound, id)
+			continue
+		}
+
+		asset, err := s.ReadAsset(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		result.Found = append(result.Found, QueryResult{Key: id, Record: asset})
+	}
+
+	return result, nil
+}
+
+// AssetExists returns true when asset with given ID exists in world state
+func (s *SmartContract) AssetExists(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
+	if isReservedKey(id) {
+		return false, nil
+	}
+
+	key, err := assetKey(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	assetJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	return assetJSON != nil, nil
+}
+
+// TransferAsset updates the owner field of asset with given id in world state, keeping any co-owners.
+// Approvals are kept too unless resetApprovalsOnTransfer is enabled. Only the owners, an admin or, in ACL
+// mode, a client granted write access may transfer an asset.
+// It emits an AssetTransferred event carrying both the previous and the new owner.
+func (s *SmartContract) TransferAsset(ctx contractapi.TransactionContextInterface, id string, newOwner string) error {
+	asset, err := s.readAssetForWrite(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = requireTransferAuthority(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	previousOwner := asset.Owner
+	asset.Owner = newOwner
+
+	err = applyTransferPolicy(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	err = putAsset(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	return emitTransferEvent(ctx, id, previousOwner, newOwner)
+}
+
+// emitTransferEvent emits the AssetTransferred event for the asset with given id changing hands.
+func emitTransferEvent(ctx contractapi.TransactionContextInterface, id, previousOwner, newOwner string) error {
+	timestamp, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, "AssetTransferred", TransferEvent{
+		ID:            id,
+		PreviousOwner: previousOwner,
+		NewOwner:      newOwner,
+		TxID:          ctx.GetStub().GetTxID(),
+		Timestamp:     timestamp,
+	})
+}
+
+// SimulateTransfer reports whether TransferAsset would move the asset with given id to newOwner, running the
+// same checks without writing anything. Every failed check is listed in the reasons, not only the first:
+// maintenance mode, the caller's right to transfer the asset, the approval reset policy and every validator,
+// freezes and the transfer allowlist included.
+func (s *SmartContract) SimulateTransfer(ctx contractapi.TransactionContextInterface, id string, newOwner string) (*SimResult, error) {
+	result := &SimResult{Reasons: []string{}}
+
+	err := requireWritable(ctx)
+	if err != nil {
+		result.Reasons = append(result.Reasons, err.Error())
+	}
+
+	previous, err := s.readAssetForWrite(ctx, id)
+	if err != nil {
+		result.Reasons = append(result.Reasons, err.Error())
+		return result, nil
+	}
+
+	err = requireTransferAuthority(ctx, previous)
+	if err != nil {
+		result.Reasons = append(result.Reasons, err.Error())
+	}
+
+	asset := *previous
+	asset.Owner = newOwner
+
+	err = applyTransferPolicy(ctx, &asset)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Reasons = append(result.Reasons, validationErrors(ctx, previous, &asset)...)
+	result.WouldSucceed = len(result.Reasons) == 0
+
+	return result, nil
+}
+
+// SetTransferAllowlist restricts the owners the asset with given id may be transferred to. An empty list lifts
+// the restriction. Only the current owner may change it.
+func (s *SmartContract) SetTransferAllowlist(ctx contractapi.TransactionContextInterface, id string, owners []string) error {
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	callerID, err := getClientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	if callerID != asset.Owner {
+		return fmt.Errorf("Only the owner of asset %s may change its transfer allowlist", id)
+	}
+
+	for _, owner := range owners {
+		if strings.TrimSpace(owner) == "" {
+			return fmt.Errorf("Transfer allowlist entries must not be empty")
+		}
+	}
+
+	asset.TransferAllowlist = owners
+
+	return putAsset(ctx, asset)
+}
+
+// SellAsset transfers the asset with given id to newOwner for price and records the sale in the asset's sale log.
+// It emits an AssetSold event carrying the sale record. Only the owner or an admin may sell an asset.
+func (s *SmartContract) SellAsset(ctx contractapi.TransactionContextInterface, id string, newOwner string, price int) error {
+	if price < 0 {
+		return fmt.Errorf("The price %d must not be negative", price)
+	}
+
+	asset, err := s.readAssetForWrite(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = requireOwnerOrAdmin(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	timestamp, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	sale := SaleRecord{
+		Price:     price,
+		From:      asset.Owner,
+		To:        newOwner,
+		TxID:      ctx.GetStub().GetTxID(),
+		Timestamp: timestamp,
+	}
+	asset.SaleLog = append(asset.SaleLog, sale)
+	asset.Owner = newOwner
+
+	err = applyTransferPolicy(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	err = putAsset(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, "AssetSold", sale)
+}
+
+// FreezeAsset freezes the asset with given id against every change, transfers and deletion included, until
+// it is unfrozen. Freezing is separate from escrow and leaves the owners as they are. Only admins may freeze an asset.
+func (s *SmartContract) FreezeAsset(ctx contractapi.TransactionContextInterface, id string) error {
+	err := requireAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+	if asset.Frozen {
+		return fmt.Errorf("The asset %s is already frozen", id)
+	}
+
+	asset.Frozen = true
+
+	return putAsset(ctx, asset)
+}
+
+// UnfreezeAsset lifts the freeze on the asset with given id. Only admins may unfreeze an asset.
+func (s *SmartContract) UnfreezeAsset(ctx contractapi.TransactionContextInterface, id string) error {
+	err := requireAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !asset.Frozen {
+		return fmt.Errorf("The asset %s is not frozen", id)
+	}
+
+	asset.Frozen = false
+
+	return putAsset(ctx, asset)
+}
+
+// EscrowAsset hands the asset with given id to escrowAgent, who becomes its sole owner until they release it
+// with ReleaseFromEscrow. Only the owner or an admin may put an asset in escrow, and while it is in escrow
+// no other operation may change its owners.
+func (s *SmartContract) EscrowAsset(ctx contractapi.TransactionContextInterface, id, escrowAgent string) error {
+	if strings.TrimSpace(escrowAgent) == "" {
+		return fmt.Errorf("Escrow agent must not be empty")
+	}
+
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = requireOwnerOrAdmin(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	if asset.InEscrow {
+		return fmt.Errorf("The asset %s is already in escrow with %s", id, asset.Owner)
+	}
+
+	asset.Owner = escrowAgent
+	asset.Owners = nil
+	asset.InEscrow = true
+
+	return putAsset(ctx, asset)
+}
+
+// ReleaseFromEscrow releases the asset with given id from escrow to finalOwner.
+// Only the escrow agent holding the asset may release it.
+func (s *SmartContract) ReleaseFromEscrow(ctx contractapi.TransactionContextInterface, id, finalOwner string) error {
+	if strings.TrimSpace(finalOwner) == "" {
+		return fmt.Errorf("Final owner must not be empty")
+	}
+
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if !asset.InEscrow {
+		return fmt.Errorf("The asset %s is not in escrow", id)
+	}
+
+	callerID, err := getClientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	if callerID != asset.Owner {
+		return fmt.Errorf("Client %s is not the escrow agent of asset %s", callerID, id)
+	}
+
+	asset.Owner = finalOwner
+	asset.Owners = nil
+	asset.InEscrow = false
+
+	return putAsset(ctx, asset)
+}
+
+// TransferSoleOwnership makes newOwner the only owner of the asset with given id, dropping all co-owners.
+// Only an owner or an admin may transfer it. Like TransferAsset, it follows the transfer policy and emits
+// an AssetTransferred event.
+func (s *SmartContract) TransferSoleOwnership(ctx contractapi.TransactionContextInterface, id string, newOwner string) error {
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = requireCoOwnerOrAdmin(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	previousOwner := asset.Owner
+	asset.Owner = newOwner
+	asset.Owners = nil
+
+	err = applyTransferPolicy(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	err = putAsset(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	return emitTransferEvent(ctx, id, previousOwner, newOwner)
+}
+
+// AddOwner adds a co-owner to the asset with given id. Only an owner or an admin may add co-owners.
+func (s *SmartContract) AddOwner(ctx contractapi.TransactionContextInterface, id, owner string) error {
+	if strings.TrimSpace(owner) == "" {
+		return fmt.Errorf("Owner must not be empty")
+	}
+
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = requireCoOwnerOrAdmin(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	if containsString(asset.Owners, owner) {
+		return fmt.Errorf("%s already owns asset %s", owner, id)
+	}
+	asset.Owners = append(asset.Owners, owner)
+
+	return putAsset(ctx, asset)
+}
+
+// RemoveOwner removes a co-owner from the asset with given id. The primary owner can only be
+// replaced through a transfer. Only an owner or an admin may remove co-owners.
+func (s *SmartContract) RemoveOwner(ctx contractapi.TransactionContextInterface, id, owner string) error {
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = requireCoOwnerOrAdmin(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	if owner == asset.Owner {
+		return fmt.Errorf("%s is the primary owner of asset %s and can only be replaced by a transfer", owner, id)
+	}
+	if !containsString(asset.Owners, owner) {
+		return fmt.Errorf("%s does not own asset %s", owner, id)
+	}
+
+	owners := []string{}
+	for _, existing := range asset.Owners {
+		if existing != owner {
+			owners = append(owners, existing)
+		}
+	}
+	asset.Owners = owners
+
+	return putAsset(ctx, asset)
+}
+
+// requireCoOwnerOrAdmin returns an error unless the caller is one of the owners of asset or an admin.
+func requireCoOwnerOrAdmin(ctx contractapi.TransactionContextInterface, asset *Asset) error {
+	callerID, err := getClientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	if containsString(asset.Owners, callerID) {
+		return nil
+	}
+
+	return requireOwnerOrAdmin(ctx, asset)
+}
+
+// requireTransferAuthority returns an error unless the caller may hand asset to a new owner: one of its
+// owners, an admin or, in ACL mode, a client granted write access to it.
+func requireTransferAuthority(ctx contractapi.TransactionContextInterface, asset *Asset) error {
+	granted, err := hasWriteGrant(ctx, asset)
+	if err != nil || granted {
+		return err
+	}
+
+	return requireCoOwnerOrAdmin(ctx, asset)
+}
+
+// requireOwnerApproverOrAdmin returns an error unless the caller is one of the owners of asset, one of its
+// required approvers or an admin.
+func requireOwnerApproverOrAdmin(ctx contractapi.TransactionContextInterface, asset *Asset) error {
+	callerID, err := getClientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	if containsString(asset.RequiredApprovers, callerID) {
+		return nil
+	}
+
+	return requireCoOwnerOrAdmin(ctx, asset)
+}
+
+// migrateOwners fills in the owners of records written before joint ownership existed.
+func migrateOwners(asset *Asset) {
+	if len(asset.Owners) == 0 && asset.Owner != "" {
+		asset.Owners = []string{asset.Owner}
+	}
+}
+
+// syncOwners keeps the primary owner first in the owners list. When the primary owner changed from
+// previousOwner, the former primary owner is replaced while the co-owners are preserved.
+func syncOwners(asset *Asset, previousOwner string) {
+	owners := []string{}
+	if asset.Owner != "" {
+		owners = append(owners, asset.Owner)
+	}
+
+	for _, owner := range asset.Owners {
+		if owner == asset.Owner || owner == previousOwner {
+			continue
+		}
+		owners = append(owners, owner)
+	}
+
+	if len(owners) == 0 {
+		owners = nil
+	}
+	asset.Owners = owners
+}
+
+// CompareAndSwapField sets the named string field of the asset with given id to newValue, but only when
+// it currently equals expected. It returns whether the swap happened.
+func (s *SmartContract) CompareAndSwapField(ctx contractapi.TransactionContextInterface, id, field, expected, newValue string) (bool, error) {
+	asset, err := s.readAssetForWrite(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	value, err := swappableField(asset, field)
+	if err != nil {
+		return false, err
+	}
+	if *value != expected {
+		return false, nil
+	}
+
+	*value = newValue
+
+	err = putAsset(ctx, asset)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// swappableField returns a pointer to the string field of asset named by its JSON name.
+func swappableField(asset *Asset, field string) (*string, error) {
+	switch field {
+	case "description":
+		return &asset.Description, nil
+	case "owner":
+		return &asset.Owner, nil
+	}
+
+	return nil, fmt.Errorf("The field %s is not a string field that can be swapped", field)
+}
+
+// AddComment appends a comment by the caller to the asset with given id, leaving the asset's data unchanged.
+// Comments cannot be edited or removed once added.
+func (s *SmartContract) AddComment(ctx contractapi.TransactionContextInterface, id, text string) error {
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("Comment must not be empty")
+	}
+	if len(text) > maxCommentLength {
+		return fmt.Errorf("Comment is %d bytes long, at most %d are allowed", len(text), maxCommentLength)
+	}
+
+	asset, err := s.readAssetForWrite(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if len(asset.Comments) >= maxComments {
+		return fmt.Errorf("The asset %s already holds the maximum of %d comments", id, maxComments)
+	}
+
+	author, err := getClientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+
+	timestamp, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	asset.Comments = append(asset.Comments, Comment{AuthorMSP: author, Text: text, Timestamp: timestamp})
+
+	return putAsset(ctx, asset)
+}
+
+// GetComments returns the comments left on the asset with given id, oldest first.
+func (s *SmartContract) GetComments(ctx contractapi.TransactionContextInterface, id string) ([]Comment, error) {
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if asset.Comments == nil {
+		return []Comment{}, nil
+	}
+
+	return asset.Comments, nil
+}
+
+// AttachDocument anchors the off-chain document name, identified by its hex-encoded SHA-256 hash, to the asset
+// with given id. Each name can be attached once. Only the owner or an admin may attach documents.
+func (s *SmartContract) AttachDocument(ctx contractapi.TransactionContextInterface, id, name, sha256Hex, contentType string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("Document name must not be empty")
+	}
+
+	hash, err := normalizeSHA256(sha256Hex)
+	if err != nil {
+		return err
+	}
+
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = requireOwnerOrAdmin(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	for _, document := range asset.Documents {
+		if document.Name == name {
+			return fmt.Errorf("The document %s is already attached to asset %s", name, id)
+		}
+	}
+	asset.Documents = append(asset.Documents, DocumentRef{Name: name, SHA256: hash, ContentType: contentType})
+
+	return putAsset(ctx, asset)
+}
+
+// VerifyDocument reports whether sha256Hex matches the hash anchored for the document name on the asset with given id.
+func (s *SmartContract) VerifyDocument(ctx contractapi.TransactionContextInterface, id, name, sha256Hex string) (bool, error) {
+	hash, err := normalizeSHA256(sha256Hex)
+	if err != nil {
+		return false, err
+	}
+
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	for _, document := range asset.Documents {
+		if document.Name == name {
+			return document.SHA256 == hash, nil
+		}
+	}
+
+	return false, fmt.Errorf("The document %s is not attached to asset %s", name, id)
+}
+
+// normalizeSHA256 returns the lower case form of a hex-encoded SHA-256 hash, or an error if it is not one.
+func normalizeSHA256(sha256Hex string) (string, error) {
+	hash := strings.ToLower(sha256Hex)
+
+	decoded, err := hex.DecodeString(hash)
+	if err != nil || len(decoded) != sha256.Size {
+		return "", fmt.Errorf("%q is not a hex-encoded SHA-256 hash", sha256Hex)
+	}
+
+	return hash, nil
+}
+
+// SetAmount sets the amount of the asset with given id. Only the owner or an admin may set it.
+func (s *SmartContract) SetAmount(ctx contractapi.TransactionContextInterface, id string, amount int) error {
+	err := validateAmount(amount)
+	if err != nil {
+		return err
+	}
+
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = requireOwnerOrAdmin(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	asset.Amount = amount
+
+	return putAsset(ctx, asset)
+}
+
+// SetMetadata sets the metadata entry key of the asset with given id to value.
+// An empty value removes the entry. Only the owner or an admin may set it.
+func (s *SmartContract) SetMetadata(ctx contractapi.TransactionContextInterface, id, key, value string) error {
+	err := validateMetadataKey(key)
+	if err != nil {
+		return err
+	}
+
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = requireOwnerOrAdmin(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	if value == "" {
+		delete(asset.Metadata, key)
+	} else {
+		if asset.Metadata == nil {
+			asset.Metadata = make(map[string]string)
+		}
+		asset.Metadata[key] = value
+	}
+
+	return putAsset(ctx, asset)
+}
+
+// validateMetadataKey returns an error unless key only holds letters, digits, dashes and underscores.
+// Keys end up as field paths in CouchDB selectors, so anything else could change the meaning of a query.
+func validateMetadataKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("Metadata key must not be empty")
+	}
+
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return fmt.Errorf("Metadata key %q may only contain letters, digits, '-' and '_'", key)
+		}
+	}
+
+	return nil
+}
+
+// SetExternalRef sets the reference of the asset with given id in an external system.
+// An empty ref clears it. Only the owner or an admin may set it.
+func (s *SmartContract) SetExternalRef(ctx contractapi.TransactionContextInterface, id, ref string) error {
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = requireOwnerOrAdmin(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	asset.ExternalRef = ref
+
+	return putAsset(ctx, asset)
+}
+
+// GetAssetCaseInsensitive returns the asset whose ID equals id when case is ignored, or an error if more than
+// one asset differs from id only by case. Assets last written before the lower case index existed are only
+// found when id matches them exactly, until they are written again.
+func (s *SmartContract) GetAssetCaseInsensitive(ctx contractapi.TransactionContextInterface, id string) (*Asset, error) {
+	ids, err := getIndexedIDs(ctx, lowerIDIndex, strings.ToLower(id))
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(ids) {
+	case 0:
+		return s.ReadAsset(ctx, id)
+	case 1:
+		return s.ReadAsset(ctx, ids[0])
+	}
+
+	return nil, fmt.Errorf("The ID %s matches assets %s, which differ only by case", id, strings.Join(ids, ", "))
+}
+
+// GetAssetByExternalRef returns the asset carrying the given external reference.
+// It fails when more than one asset carries the reference, listing the colliding IDs.
+func (s *SmartContract) GetAssetByExternalRef(ctx contractapi.TransactionContextInterface, ref string) (*Asset, error) {
+	ids, err := getIndexedIDs(ctx, extRefIndex, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(ids) {
+	case 0:
+		return nil, fmt.Errorf("No asset has external reference %s", ref)
+	case 1:
+		return s.ReadAsset(ctx, ids[0])
+	}
+
+	return nil, fmt.Errorf("The external reference %s is shared by assets %s", ref, strings.Join(ids, ", "))
+}
+
+// SetReferences sets the assets the asset with given id refers to. Every referenced asset must exist.
+// Only the owner or an admin may set them.
+func (s *SmartContract) SetReferences(ctx contractapi.TransactionContextInterface, id string, references []string) error {
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = requireOwnerOrAdmin(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	err = s.validateReferences(ctx, id, references)
+	if err != nil {
+		return err
+	}
+
+	asset.References = references
+
+	return putAsset(ctx, asset)
+}
+
+// validateReferences returns an error unless every asset that the asset with given id refers to exists.
+func (s *SmartContract) validateReferences(ctx contractapi.TransactionContextInterface, id string, references []string) error {
+	for _, reference := range references {
+		if reference == id {
+			return fmt.Errorf("The asset %s cannot reference itself", id)
+		}
+
+		exists, err := s.AssetExists(ctx, reference)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("The referenced asset %s does not exist", reference)
+		}
+	}
+
+	return nil
+}
+
+// Change ApprovalOne to 1 from 0
+func (s * SmartContract) ApproveRequestOne(ctx contractapi.TransactionContextInterface, id string) error{
+	// approvers are rarely owners, so ACL mode does not cover approvals
+	asset, err := readStoredAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = requireSubmitted(asset)
+	if err != nil {
+		return err
+	}
+
+	err = recordApproverSignature(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	err = appendApprovalLog(ctx, asset, "approvalOne")
+	if err != nil {
+		return err
+	}
+
+	err = advanceState(asset, StateApprovedOne)
+	if err != nil {
+		return err
+	}
+
+	asset.ApprovalOne = 1
+
+	_, err = putAssetIfChanged(ctx, asset)
+	return err
+
+ }
+
+
+// Change ApprovalTwo to 1 from 0
+func (s * SmartContract) ApproveRequestTwo(ctx contractapi.TransactionContextInterface, id string) error{
+	// read without the ACL check, like ApproveRequestOne
+	asset, err := readStoredAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = requireSubmitted(asset)
+	if err != nil {
+		return err
+	}
+
+	signatures := len(asset.SignedApprovers)
+	err = recordApproverSignature(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	// an approver signing again while others are still missing is not an approval, so it is not logged and
+	// leaves the asset as it is
+	repeated := len(asset.RequiredApprovers) > 0 && len(asset.SignedApprovers) == signatures && !allApproversSigned(asset)
+	if !repeated {
+		err = appendApprovalLog(ctx, asset, "approvalTwo")
+		if err != nil {
+			return err
+		}
+	}
+
+	if !allApproversSigned(asset) {
+		// the signature is kept, but registration waits for the remaining required approvers
+		_, err = putAssetIfChanged(ctx, asset)
+		return err
+	}
+
+	// the second approval registers the asset, so it passes through both states
+	err = advanceState(asset, StateApprovedTwo)
+	if err != nil {
+		return err
+	}
+	err = advanceState(asset, StateRegistered)
+	if err != nil {
+		return err
+	}
+
+	asset.ApprovalTwo = 1
+	asset.Registered = 1
+
+	// registering an amended asset is what makes the amendment take effect
+	if asset.PendingAmendment != nil {
+		asset.Description = asset.PendingAmendment.Description
+		asset.PendingAmendment = nil
+	}
+
+	_, err = putAssetIfChanged(ctx, asset)
+	return err
+
+ }
+
+// AmendAsset proposes a new description for the registered asset with given id. The change is held as a
+// pending amendment and the asset goes through both approvals again; it only takes effect once the asset
+// is registered anew, while rejecting it keeps the registered description. Only the owner or an admin may
+// propose an amendment.
+func (s *SmartContract) AmendAsset(ctx contractapi.TransactionContextInterface, id, newDescription string) error {
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = requireOwnerOrAdmin(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	err = advanceState(asset, StatePendingAmendment)
+	if err != nil {
+		return err
+	}
+
+	proposer, err := getClientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+
+	proposedAt, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	asset.PendingAmendment = &Amendment{Description: newDescription, ProposedBy: proposer, ProposedAt: proposedAt}
+	asset.ApprovalOne = 0
+	asset.ApprovalTwo = 0
+	asset.SignedApprovers = nil
+
+	return putAsset(ctx, asset)
+}
+
+// SetRequiredApprovers sets the identities that must all approve the asset with given id before it is
+// registered. Only the owner or an admin may set them, and only before approvals have started.
+// An empty list restores the default two-party approval flow.
+func (s *SmartContract) SetRequiredApprovers(ctx contractapi.TransactionContextInterface, id string, approvers []string) error {
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = requireOwnerOrAdmin(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	state := currentState(asset)
+	if state != StateDraft && state != StateSubmitted {
+		return fmt.Errorf("The required approvers of asset %s cannot change in state %s", id, state)
+	}
+
+	for _, approver := range approvers {
+		if strings.TrimSpace(approver) == "" {
+			return fmt.Errorf("Required approvers must not be empty")
+		}
+	}
+
+	asset.RequiredApprovers = approvers
+	asset.SignedApprovers = nil
+
+	return putAsset(ctx, asset)
+}
+
+// recordApproverSignature records the caller as having signed an asset with required approvers,
+// rejecting callers that are not on the list. Assets without required approvers are left untouched.
+func recordApproverSignature(ctx contractapi.TransactionContextInterface, asset *Asset) error {
+	if len(asset.RequiredApprovers) == 0 {
+		return nil
+	}
+
+	approver, err := getClientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !containsString(asset.RequiredApprovers, approver) {
+		return fmt.Errorf("Client %s is not a required approver of asset %s", approver, asset.ID)
+	}
+	if !containsString(asset.SignedApprovers, approver) {
+		asset.SignedApprovers = append(asset.SignedApprovers, approver)
+	}
+
+	return nil
+}
+
+// allApproversSigned reports whether every required approver of asset has signed.
+func allApproversSigned(asset *Asset) bool {
+	for _, approver := range asset.RequiredApprovers {
+		if !containsString(asset.SignedApprovers, approver) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// GetRequiredApprovers returns the approvers expected to approve the asset with given id and whether each has signed.
+// Assets without named approvers accept approvals from any client, so their two entries name whoever granted
+// the first and second approval and are left empty while that approval is pending.
+func (s *SmartContract) GetRequiredApprovers(ctx contractapi.TransactionContextInterface, id string) ([]ApproverStatus, error) {
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	approvers := []ApproverStatus{}
+	if len(asset.RequiredApprovers) > 0 {
+		for _, approver := range asset.RequiredApprovers {
+			approvers = append(approvers, ApproverStatus{Approver: approver, Signed: containsString(asset.SignedApprovers, approver)})
+		}
+
+		return approvers, nil
+	}
+
+	for _, approval := range []struct {
+		approvalType string
+		granted      bool
+	}{{"approvalOne", asset.ApprovalOne == 1}, {"approvalTwo", asset.ApprovalTwo == 1}} {
+		status := ApproverStatus{Signed: approval.granted}
+		if approval.granted {
+			status.Approver = latestApprover(asset, approval.approvalType)
+		}
+		approvers = append(approvers, status)
+	}
+
+	return approvers, nil
+}
+
+// latestApprover returns the approver of the most recent approval of the given type in the approval log of asset.
+func latestApprover(asset *Asset, approvalType string) string {
+	for i := len(asset.ApprovalLog) - 1; i >= 0; i-- {
+		if asset.ApprovalLog[i].ApprovalType == approvalType {
+			return asset.ApprovalLog[i].ApproverMSP
+		}
+	}
+
+	return ""
+}
+
+// ResetApprovals returns the asset with given id to draft, withdrawing all of its approvals, its
+// registration and any pending amendment. The reset is recorded in the approval log. Only admins may reset.
+func (s *SmartContract) ResetApprovals(ctx contractapi.TransactionContextInterface, id string) error {
+	err := requireAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = resetApprovals(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	return putAsset(ctx, asset)
+}
+
+// resetApprovals returns asset to draft, withdrawing its approvals, registration and pending amendment,
+// and records the reset in its approval log.
+func resetApprovals(ctx contractapi.TransactionContextInterface, asset *Asset) error {
+	asset.ApprovalOne = 0
+	asset.ApprovalTwo = 0
+	asset.Registered = 0
+	asset.SignedApprovers = nil
+	asset.PendingAmendment = nil
+	asset.Status = StateDraft
+
+	return appendApprovalLog(ctx, asset, "reset")
+}
+
+// SetResetApprovalsOnTransfer makes TransferAsset and SellAsset return the asset to draft when enabled, so that
+// it has to be approved again under its new owner. Only admins may change it.
+func (s *SmartContract) SetResetApprovalsOnTransfer(ctx contractapi.TransactionContextInterface, enabled bool) error {
+	return setConfigBool(ctx, resetApprovalsOnTransferConfig, enabled)
+}
+
+// applyTransferPolicy resets the approvals of an asset changing owner when the deployment asks for it.
+// Drafts have nothing to reset and are left as they are.
+func applyTransferPolicy(ctx contractapi.TransactionContextInterface, asset *Asset) error {
+	reset, err := getConfigBool(ctx, resetApprovalsOnTransferConfig)
+	if err != nil {
+		return err
+	}
+	if !reset || currentState(asset) == StateDraft {
+		return nil
+	}
+
+	return resetApprovals(ctx, asset)
+}
+
+// GetApprovalLog returns the approvals recorded on the asset with given id, oldest first.
+// Every approval outside of an admin override passes through ApproveRequestOne or ApproveRequestTwo and
+// is recorded here; flags an admin sets directly with UpdateAsset are not approvals and leave no record.
+func (s *SmartContract) GetApprovalLog(ctx contractapi.TransactionContextInterface, id string) ([]ApprovalRecord, error) {
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if asset.ApprovalLog == nil {
+		return []ApprovalRecord{}, nil
+	}
+
+	return asset.ApprovalLog, nil
+}
+
+// appendApprovalLog records an approval of the given type by the caller on asset,
+// dropping the oldest entries once the log exceeds maxApprovalLogEntries.
+func appendApprovalLog(ctx contractapi.TransactionContextInterface, asset *Asset, approvalType string) error {
+	approver, err := getClientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+
+	timestamp, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	asset.ApprovalLog = append(asset.ApprovalLog, ApprovalRecord{
+		ApproverMSP:  approver,
+		ApprovalType: approvalType,
+		TxID:         ctx.GetStub().GetTxID(),
+		Timestamp:    timestamp,
+	})
+	if len(asset.ApprovalLog) > maxApprovalLogEntries {
+		asset.ApprovalLog = asset.ApprovalLog[len(asset.ApprovalLog)-maxApprovalLogEntries:]
+	}
+
+	return nil
+}
+
+// SubmitForApproval submits the draft asset with given id for review, after which it can be approved.
+// Only the owner of the asset may submit it.
+func (s *SmartContract) SubmitForApproval(ctx contractapi.TransactionContextInterface, id string) error {
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	callerID, err := getClientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	if callerID != asset.Owner {
+		return fmt.Errorf("Only the owner of asset %s may submit it for approval", id)
+	}
+
+	err = advanceState(asset, StateSubmitted)
+	if err != nil {
+		return err
+	}
+
+	return putAsset(ctx, asset)
+}
+
+// requireSubmitted returns an error while asset is still a draft that has not been submitted for approval.
+func requireSubmitted(asset *Asset) error {
+	if currentState(asset) == StateDraft {
+		return fmt.Errorf("The asset %s must be submitted for approval before it can be approved", asset.ID)
+	}
+
+	return nil
+}
+
+// RejectRequest moves a pending asset to the rejected state, ending its approval flow.
+// For an asset with a pending amendment only the amendment is rejected.
+// Only an owner, a required approver or an admin may reject.
+func (s *SmartContract) RejectRequest(ctx contractapi.TransactionContextInterface, id string) error {
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = requireOwnerApproverOrAdmin(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	// rejecting an amendment discards it, leaving the asset registered as it was
+	if asset.PendingAmendment != nil {
+		err = advanceState(asset, StateRegistered)
+		if err != nil {
+			return err
+		}
+
+		asset.PendingAmendment = nil
+		asset.ApprovalOne = 1
+		asset.ApprovalTwo = 1
+
+		return putAsset(ctx, asset)
+	}
+
+	err = advanceState(asset, StateRejected)
+	if err != nil {
+		return err
+	}
+
+	return putAsset(ctx, asset)
+}
+
+// GetAllowedTransitions returns the lifecycle states the asset with given id may move to next.
+func (s *SmartContract) GetAllowedTransitions(ctx contractapi.TransactionContextInterface, id string) ([]string, error) {
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := []string{}
+	for _, next := range allowedTransitions(asset) {
+		allowed = append(allowed, string(next))
+	}
+
+	return allowed, nil
+}
+
+// currentState returns the lifecycle state of asset. Records written before the state was stored
+// have it derived from their approval flags.
+func currentState(asset *Asset) AssetState {
+	if asset.Status != "" {
+		return asset.Status
+	}
+
+	return stateFromFlags(asset, StateDraft)
+}
+
+// stateFromFlags derives the lifecycle state implied by the approval flags of asset, falling back to
+// fallback when no approval has been granted.
+func stateFromFlags(asset *Asset, fallback AssetState) AssetState {
+	switch {
+	case asset.Registered == 1:
+		return StateRegistered
+	case asset.ApprovalTwo == 1:
+		return StateApprovedTwo
+	case asset.ApprovalOne == 1:
+		return StateApprovedOne
+	case fallback == StateDraft || fallback == StateSubmitted || fallback == StateRejected:
+		return fallback
+	}
+
+	return StateDraft
+}
+
+// expectedState derives the lifecycle state the flags of asset call for, taking the states that the flags
+// alone cannot express, such as a rejection or a pending amendment, from the rest of the record.
+func expectedState(asset *Asset) AssetState {
+	if asset.PendingAmendment != nil {
+		if asset.ApprovalOne == 1 {
+			return StateApprovedOne
+		}
+		return StatePendingAmendment
+	}
+	if asset.Status == StateRejected && asset.Registered != 1 {
+		return StateRejected
+	}
+
+	return stateFromFlags(asset, asset.Status)
+}
+
+// GetInconsistentAssets returns the assets whose stored status disagrees with the status their approval
+// flags call for. Records written before the status was stored carry none and are not reported.
+func (s *SmartContract) GetInconsistentAssets(ctx contractapi.TransactionContextInterface) ([]QueryResult, error) {
+	return getAssetsWhere(ctx, func(asset *Asset) bool {
+		return asset.Status != "" && asset.Status != expectedState(asset)
+	})
+}
+
+// validateTransition returns an error unless the lifecycle allows moving from one state to the next.
+func validateTransition(from, to AssetState) error {
+	for _, next := range stateTransitions[from] {
+		if next == to {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("The asset cannot move from state %s to state %s", from, to)
+}
+
+// allowedTransitions returns the states asset may move to next, taking a pending amendment into account.
+func allowedTransitions(asset *Asset) []AssetState {
+	state := currentState(asset)
+	if asset.PendingAmendment != nil {
+		if next, ok := amendmentTransitions[state]; ok {
+			return next
+		}
+	}
+
+	return stateTransitions[state]
+}
+
+// advanceState moves asset to the given lifecycle state if the transition is allowed.
+func advanceState(asset *Asset, to AssetState) error {
+	for _, next := range allowedTransitions(asset) {
+		if next == to {
+			asset.Status = to
+			return nil
+		}
+	}
+
+	return fmt.Errorf("The asset cannot move from state %s to state %s", currentState(asset), to)
+}
+
+// GetApprovalProgress returns the approval status of the asset with given id in a structured form.
+func (s *SmartContract) GetApprovalProgress(ctx contractapi.TransactionContextInterface, id string) (*ApprovalProgress, error) {
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return approvalProgress(asset), nil
+}
+
+// ReadAssetComputed returns the asset with given id along with its approval progress, whether it is registered,
+// its age in seconds as of this transaction and its lifecycle state. Nothing derived is stored.
+func (s *SmartContract) ReadAssetComputed(ctx contractapi.TransactionContextInterface, id string) (*AssetView, error) {
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	view := &AssetView{
+		Asset:            asset,
+		ApprovalProgress: *approvalProgress(asset),
+		IsRegistered:     asset.Registered == 1,
+		Status:           string(currentState(asset)),
+	}
+
+	if !asset.CreatedAt.IsZero() {
+		now, err := getTxTime(ctx)
+		if err != nil {
+			return nil, err
+		}
+		view.AgeSeconds = int64(now.Sub(asset.CreatedAt) / time.Second)
+	}
+
+	return view, nil
+}
+
+// approvalProgress summarizes how far asset is through the approval flow.
+func approvalProgress(asset *Asset) *ApprovalProgress {
+	granted := approvalsGranted(asset)
+	required := approvalsRequired(asset)
+
+	stage := "registered"
+	if asset.Registered != 1 || asset.PendingAmendment != nil {
+		switch {
+		case asset.ApprovalOne != 1:
+			stage = "awaiting first approval"
+		case asset.ApprovalTwo != 1:
+			stage = "awaiting second approval"
+		default:
+			stage = "awaiting registration"
+		}
+	}
+
+	return &ApprovalProgress{
+		ApprovalsGranted:  granted,
+		ApprovalsRequired: required,
+		Percentage:        granted * 100 / required,
+		Stage:             stage,
+	}
+}
+
+// approvalsGranted returns how many of the required approvals the asset has received.
+// For assets with named approvers that is the number of them who signed.
+func approvalsGranted(asset *Asset) int {
+	if len(asset.RequiredApprovers) > 0 {
+		granted := 0
+		for _, approver := range asset.RequiredApprovers {
+			if containsString(asset.SignedApprovers, approver) {
+				granted++
+			}
+		}
+
+		return granted
+	}
+
+	granted := 0
+	if asset.ApprovalOne == 1 {
+		granted++
+	}
+	if asset.ApprovalTwo == 1 {
+		granted++
+	}
+
+	return granted
+}
+
+// approvalsRequired returns how many approvals the asset needs before it is registered.
+func approvalsRequired(asset *Asset) int {
+	if len(asset.RequiredApprovers) > 0 {
+		return len(asset.RequiredApprovers)
+	}
+
+	return requiredApprovals
+}
+
+// QueryAssetsByMinApprovals returns the assets that have been granted at least minApprovals approvals.
+func (s *SmartContract) QueryAssetsByMinApprovals(ctx contractapi.TransactionContextInterface, minApprovals int) ([]QueryResult, error) {
+	if minApprovals < 0 {
+		return nil, fmt.Errorf("The approval threshold must not be negative, got %d", minApprovals)
+	}
+
+	return getAssetsWhere(ctx, func(asset *Asset) bool {
+		return approvalsGranted(asset) >= minApprovals
+	})
+}
+
+// GetAllAssets returns all assets found in world state, sorted by asset ID. The order is established here
+// rather than taken from the range query, so it holds however the keys are laid out in the world state.
+func (s *SmartContract) GetAllAssets(ctx contractapi.TransactionContextInterface) ([]QueryResult, error) {
+	assets, err := getAssetsWhere(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sortByID(assets)
+
+	return assets, nil
+}
+
+// sortByID orders query results by asset ID.
+func sortByID(results []QueryResult) {
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Key < results[j].Key
+	})
+}
+
+// GetAssetsLimited returns the first limit assets in order of their IDs and whether more follow. The scan
+// stops as soon as it knows, so the cost does not grow with the size of the ledger. limit must lie between
+// 1 and maxAssetsLimit.
+func (s *SmartContract) GetAssetsLimited(ctx contractapi.TransactionContextInterface, limit int) (*LimitedQueryResult, error) {
+	if limit <= 0 || limit > maxAssetsLimit {
+		return nil, fmt.Errorf("The limit must be between 1 and %d, got %d", maxAssetsLimit, limit)
+	}
+
+	match, err := readableFilter(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	results, more, err := scanAssetsInRange(ctx, "", "", limit, match)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LimitedQueryResult{Records: results, HasMore: more}, nil
+}
+
+// GetAssetsCreatedBy returns all assets originally created by a client of the given MSP.
+func (s *SmartContract) GetAssetsCreatedBy(ctx contractapi.TransactionContextInterface, mspID string) ([]QueryResult, error) {
+	return getAssetsWhere(ctx, func(asset *Asset) bool {
+		return asset.CreatedByMSP == mspID
+	})
+}
+
+// GetOwnerAssetsSorted returns the assets owned by owner ordered by creation time, oldest first for order "asc"
+// and newest first for "desc". Assets created at the same time are ordered by ID in either case.
+func (s *SmartContract) GetOwnerAssetsSorted(ctx contractapi.TransactionContextInterface, owner, order string) ([]QueryResult, error) {
+	if order != "asc" && order != "desc" {
+		return nil, fmt.Errorf("Unknown sort order %s. Expected asc or desc", order)
+	}
+
+	assets, err := getAssetsWhere(ctx, func(asset *Asset) bool {
+		return asset.Owner == owner
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(assets, func(i, j int) bool {
+		a, b := assets[i].Record, assets[j].Record
+		if a.CreatedAt.Equal(b.CreatedAt) {
+			return a.ID < b.ID
+		}
+		if order == "desc" {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+
+	return assets, nil
+}
+
+// GetAssetsApprovedBy returns the assets whose approval log holds an approval granted by a client of the given MSP.
+// Resets are not approvals and are ignored, and approvals dropped from a full log are no longer found.
+func (s *SmartContract) GetAssetsApprovedBy(ctx contractapi.TransactionContextInterface, mspID string) ([]QueryResult, error) {
+	return getAssetsWhere(ctx, func(asset *Asset) bool {
+		for _, approval := range asset.ApprovalLog {
+			if approval.ApproverMSP == mspID && approval.ApprovalType != "reset" {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+// GetAssetsByCreationSource returns the assets created with the given source, "manual" for IDs chosen by the
+// caller or "auto" for IDs the chaincode generated. Assets created before sources were recorded have none.
+func (s *SmartContract) GetAssetsByCreationSource(ctx contractapi.TransactionContextInterface, source string) ([]QueryResult, error) {
+	if source != CreationSourceManual && source != CreationSourceAuto {
+		return nil, fmt.Errorf("Unknown creation source %s. Expected %s or %s", source, CreationSourceManual, CreationSourceAuto)
+	}
+
+	return getAssetsWhere(ctx, func(asset *Asset) bool {
+		return asset.CreationSource == source
+	})
+}
+
+// GetAssetsEverOwnedBy returns the assets owner holds now or held at any point in their history. It reads the
+// full history of every asset, so its cost grows with the number of assets times the number of changes to each;
+// use GetOwnerAssetsSorted when only current ownership matters. Deleted assets are not returned.
+func (s *SmartContract) GetAssetsEverOwnedBy(ctx contractapi.TransactionContextInterface, owner string) ([]QueryResult, error) {
+	assets, err := getAssetsWhere(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []QueryResult{}
+	for _, asset := range assets {
+		if asset.Record.Owner == owner {
+			results = append(results, asset)
+			continue
+		}
+
+		history, err := getAssetHistory(ctx, asset.Key)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range history {
+			if !entry.IsDelete && entry.Record.Owner == owner {
+				results = append(results, asset)
+				break
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// GetLatestAssetForOwner returns the most recently created asset owned by owner.
+// Assets created at the same time are ordered by ID, so the result is the same on every peer.
+func (s *SmartContract) GetLatestAssetForOwner(ctx contractapi.TransactionContextInterface, owner string) (*Asset, error) {
+	assets, err := getAssetsWhere(ctx, func(asset *Asset) bool {
+		return asset.Owner == owner
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *Asset
+	for _, asset := range assets {
+		if latest == nil || asset.Record.CreatedAt.After(latest.CreatedAt) ||
+			(asset.Record.CreatedAt.Equal(latest.CreatedAt) && asset.Record.ID > latest.ID) {
+			latest = asset.Record
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("The owner %s does not own any assets", owner)
+	}
+
+	return latest, nil
+}
+
+// GetStalePendingAssets returns the unregistered, unrejected assets created more than olderThanHours hours
+// before the current transaction.
+func (s *SmartContract) GetStalePendingAssets(ctx contractapi.TransactionContextInterface, olderThanHours int) ([]QueryResult, error) {
+	if olderThanHours <= 0 {
+		return nil, fmt.Errorf("The age %d must be a positive number of hours", olderThanHours)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cutoff := now.Add(-time.Duration(olderThanHours) * time.Hour)
+
+	return getAssetsWhere(ctx, func(asset *Asset) bool {
+		return asset.Registered != 1 && currentState(asset) != StateRejected && asset.CreatedAt.Before(cutoff)
+	})
+}
+
+// ExportAssets returns every asset projected to the fields named by fieldsJSON, a JSON array of asset JSON
+// field names. String fields are exported as they are and all other fields as their JSON encoding;
+// fields an asset leaves empty are exported as empty strings. With sorted set the rows are ordered by asset ID,
+// so every peer exports the same ledger byte for byte, whatever order the state database returns it in.
+func (s *SmartContract) ExportAssets(ctx contractapi.TransactionContextInterface, fieldsJSON string, sorted bool) ([]map[string]string, error) {
+	var fields []string
+	err := json.Unmarshal([]byte(fieldsJSON), &fields)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse the export fields. %s", err.Error())
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("At least one field must be exported")
+	}
+
+	known := assetFieldNames()
+	for _, field := range fields {
+		if !known[field] {
+			return nil, fmt.Errorf("The field %s is not an asset field", field)
+		}
+	}
+
+	assets, err := getAssetsWhere(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if sorted {
+		sortByID(assets)
+	}
+
+	rows := []map[string]string{}
+	for _, asset := range assets {
+		document, err := assetDocument(asset.Record)
+		if err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]string, len(fields))
+		for _, field := range fields {
+			row[field] = exportValue(document[field])
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// GetAssetsMissingField returns the assets whose field with given JSON name is absent or holds its zero value,
+// such as records written before the field was introduced.
+func (s *SmartContract) GetAssetsMissingField(ctx contractapi.TransactionContextInterface, fieldName string) ([]QueryResult, error) {
+	if !assetFieldNames()[fieldName] {
+		return nil, fmt.Errorf("The field %s is not an asset field", fieldName)
+	}
+
+	assets, err := getAssetsWhere(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	missing := []QueryResult{}
+	for _, asset := range assets {
+		document, err := assetDocument(asset.Record)
+		if err != nil {
+			return nil, err
+		}
+
+		if isZeroJSON(document[fieldName]) {
+			missing = append(missing, asset)
+		}
+	}
+
+	return missing, nil
+}
+
+// assetDocument returns the JSON encoding of asset split into its fields.
+func assetDocument(asset *Asset) (map[string]json.RawMessage, error) {
+	assetJSON, err := json.Marshal(asset)
+	if err != nil {
+		return nil, err
+	}
+
+	var document map[string]json.RawMessage
+	err = json.Unmarshal(assetJSON, &document)
+	if err != nil {
+		return nil, err
+	}
+
+	return document, nil
+}
+
+// isZeroJSON reports whether raw is absent or encodes a zero value.
+func isZeroJSON(raw json.RawMessage) bool {
+	switch string(raw) {
+	case "", "null", `""`, "0", "false", "[]", "{}":
+		return true
+	}
+
+	var timestamp time.Time
+	if json.Unmarshal(raw, &timestamp) == nil {
+		return timestamp.IsZero()
+	}
+
+	return false
+}
+
+// assetFieldNames returns the JSON names of the fields of Asset.
+func assetFieldNames() map[string]bool {
+	names := make(map[string]bool)
+	assetType := reflect.TypeOf(Asset{})
+	for i := 0; i < assetType.NumField(); i++ {
+		name := strings.Split(assetType.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+
+	return names
+}
+
+// exportValue renders a raw JSON value for ExportAssets, unquoting strings.
+func exportValue(raw json.RawMessage) string {
+	if raw == nil {
+		return ""
+	}
+
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return text
+	}
+
+	return string(raw)
+}
+
+// GetAssetsRegisteredBetween returns the assets registered at or after start and before end, both given in RFC 3339 format.
+// Assets registered before registration times were recorded are not returned.
+func (s *SmartContract) GetAssetsRegisteredBetween(ctx contractapi.TransactionContextInterface, startRFC3339, endRFC3339 string) ([]QueryResult, error) {
+	start, end, err := parseTimeWindow(startRFC3339, endRFC3339)
+	if err != nil {
+		return nil, err
+	}
+
+	return getAssetsWhere(ctx, func(asset *Asset) bool {
+		return asset.Registered == 1 && !asset.RegisteredAt.IsZero() && !asset.RegisteredAt.Before(start) && asset.RegisteredAt.Before(end)
+	})
+}
+
+// GetAssetsModifiedBetween returns the assets last written at or after start and at or before end, both given
+// in RFC 3339 format. Records last written before update times were recorded carry none and are not returned.
+func (s *SmartContract) GetAssetsModifiedBetween(ctx contractapi.TransactionContextInterface, startRFC3339, endRFC3339 string) ([]QueryResult, error) {
+	start, end, err := parseTimeWindow(startRFC3339, endRFC3339)
+	if err != nil {
+		return nil, err
+	}
+
+	// both bounds are inclusive, so an asset written exactly at start or end is part of the window
+	return getAssetsWhere(ctx, func(asset *Asset) bool {
+		return !asset.UpdatedAt.IsZero() && !asset.UpdatedAt.Before(start) && !asset.UpdatedAt.After(end)
+	})
+}
+
+// GetAssetCountSince returns how many assets were created strictly after since, given in RFC 3339 format.
+// Records created before creation times were recorded carry none and are not counted.
+func (s *SmartContract) GetAssetCountSince(ctx contractapi.TransactionContextInterface, sinceRFC3339 string) (int, error) {
+	since, err := time.Parse(time.RFC3339, sinceRFC3339)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to parse since time. %s", err.Error())
+	}
+
+	assets, err := getAssetsWhere(ctx, func(asset *Asset) bool {
+		return !asset.CreatedAt.IsZero() && asset.CreatedAt.After(since)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(assets), nil
+}
+
+// parseTimeWindow parses the RFC 3339 bounds of a time window, rejecting an end before the start.
+func parseTimeWindow(startRFC3339, endRFC3339 string) (time.Time, time.Time, error) {
+	start, err := time.Parse(time.RFC3339, startRFC3339)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("Failed to parse start time. %s", err.Error())
+	}
+
+	end, err := time.Parse(time.RFC3339, endRFC3339)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("Failed to parse end time. %s", err.Error())
+	}
+
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("The end time %s is before the start time %s", endRFC3339, startRFC3339)
+	}
+
+	return start, end, nil
+}
+
+// GetRecentRegistrations returns the n most recently registered assets, newest first. n is capped at
+// maxRecentRegistrations. Assets registered at the same time are ordered by ID, and assets registered before
+// registration times were recorded are not returned.
+func (s *SmartContract) GetRecentRegistrations(ctx contractapi.TransactionContextInterface, n int) ([]QueryResult, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("The number of registrations must be greater than zero, got %d", n)
+	}
+	if n > maxRecentRegistrations {
+		n = maxRecentRegistrations
+	}
+
+	assets, err := getAssetsWhere(ctx, func(asset *Asset) bool {
+		return asset.Registered == 1 && !asset.RegisteredAt.IsZero()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(assets, func(i, j int) bool {
+		a, b := assets[i].Record, assets[j].Record
+		if a.RegisteredAt.Equal(b.RegisteredAt) {
+			return a.ID < b.ID
+		}
+
+		return a.RegisteredAt.After(b.RegisteredAt)
+	})
+
+	if len(assets) > n {
+		assets = assets[:n]
+	}
+
+	return assets, nil
+}
+
+// GetAssetsRegisteredByPair returns the registered assets whose two approvals came from mspA and mspB, in either
+// order. Assets registered before the approving MSPs were recorded are not returned.
+func (s *SmartContract) GetAssetsRegisteredByPair(ctx contractapi.TransactionContextInterface, mspA, mspB string) ([]QueryResult, error) {
+	return getAssetsWhere(ctx, func(asset *Asset) bool {
+		pair := asset.RegisteredByMSPs
+		return asset.Registered == 1 && pair[0] != "" && (pair == [2]string{mspA, mspB} || pair == [2]string{mspB, mspA})
+	})
+}
+
+// GetUnmodifiedAssets returns the assets that have not been written since they were created.
+// Records last written before update times were recorded carry none and are not returned.
+func (s *SmartContract) GetUnmodifiedAssets(ctx contractapi.TransactionContextInterface) ([]QueryResult, error) {
+	return getAssetsWhere(ctx, func(asset *Asset) bool {
+		return !asset.UpdatedAt.IsZero() && asset.UpdatedAt.Equal(asset.CreatedAt)
+	})
+}
+
+// GetOwnerlessAssets returns all assets whose owner is empty or only whitespace.
+func (s *SmartContract) GetOwnerlessAssets(ctx contractapi.TransactionContextInterface) ([]QueryResult, error) {
+	return getAssetsWhere(ctx, func(asset *Asset) bool {
+		return strings.TrimSpace(asset.Owner) == ""
+	})
+}
+
+// GetOwnersAboveCount returns the number of assets held by each owner holding more than threshold assets.
+func (s *SmartContract) GetOwnersAboveCount(ctx contractapi.TransactionContextInterface, threshold int) (map[string]int, error) {
+	if threshold < 0 {
+		return nil, fmt.Errorf("The count threshold must not be negative, got %d", threshold)
+	}
+
+	assets, err := getAssetsWhere(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, asset := range assets {
+		counts[asset.Record.Owner]++
+	}
+
+	for owner, count := range counts {
+		if count <= threshold {
+			delete(counts, owner)
+		}
+	}
+
+	return counts, nil
+}
+
+// GetOwnerDistribution returns each owner's share of all assets as a percentage. An empty ledger has no owners.
+func (s *SmartContract) GetOwnerDistribution(ctx contractapi.TransactionContextInterface) (map[string]float64, error) {
+	assets, err := getAssetsWhere(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	distribution := make(map[string]float64)
+	if len(assets) == 0 {
+		return distribution, nil
+	}
+
+	counts := make(map[string]int)
+	for _, asset := range assets {
+		counts[asset.Record.Owner]++
+	}
+
+	for owner, count := range counts {
+		distribution[owner] = float64(count) * 100 / float64(len(assets))
+	}
+
+	return distribution, nil
+}
+
+// GetTagUsage returns how many assets carry each tag.
+func (s *SmartContract) GetTagUsage(ctx contractapi.TransactionContextInterface) (map[string]int, error) {
+	assets, err := getAssetsWhere(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make(map[string]int)
+	for _, asset := range assets {
+		for _, tag := range asset.Record.Tags {
+			usage[tag]++
+		}
+	}
+
+	return usage, nil
+}
+
+// GroupAssetsByOwner returns all assets found in world state bucketed by owner.
+// The world state is scanned once, but every asset is held in memory until the map is returned,
+// so on large ledgers prefer owner-specific queries over grouping everything in one call.
+func (s *SmartContract) GroupAssetsByOwner(ctx contractapi.TransactionContextInterface) (map[string][]QueryResult, error) {
+	assets, err := getAssetsWhere(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string][]QueryResult)
+	for _, asset := range assets {
+		groups[asset.Record.Owner] = append(groups[asset.Record.Owner], asset)
+	}
+
+	return groups, nil
+}
+
+// QueryAssetsByField returns the assets whose field, one of the JSON names in queryableAssetFields, equals value.
+// It uses a CouchDB rich query, so it requires CouchDB as the state database.
+func (s *SmartContract) QueryAssetsByField(ctx contractapi.TransactionContextInterface, field, value string) ([]QueryResult, error) {
+	if !queryableAssetFields[field] {
+		fields := []string{}
+		for name := range queryableAssetFields {
+			fields = append(fields, name)
+		}
+		sort.Strings(fields)
+
+		return nil, fmt.Errorf("The field %s cannot be queried. Expected one of %s", field, strings.Join(fields, ", "))
+	}
+
+	selector := map[string]interface{}{
+		"selector": map[string]interface{}{
+			field: value,
+		},
+	}
+
+	queryString, err := json.Marshal(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	return getAssetsByQuery(ctx, string(queryString))
+}
+
+// QueryAssetsByMetadata returns the assets whose metadata entry key equals value.
+// It uses a CouchDB rich query, so it requires CouchDB as the state database.
+func (s *SmartContract) QueryAssetsByMetadata(ctx contractapi.TransactionContextInterface, key, value string) ([]QueryResult, error) {
+	err := validateMetadataKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	selector := map[string]interface{}{
+		"selector": map[string]interface{}{
+			"metadata." + key: value,
+		},
+	}
+
+	queryString, err := json.Marshal(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	return getAssetsByQuery(ctx, string(queryString))
+}
+
+// GetAmountStatistics returns the minimum, maximum, sum and average of the amounts of all assets.
+// Every statistic is zero when there are no assets.
+func (s *SmartContract) GetAmountStatistics(ctx contractapi.TransactionContextInterface) (*AmountStats, error) {
+	assets, err := getAssetsWhere(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &AmountStats{}
+	for i, asset := range assets {
+		amount := asset.Record.Amount
+		if i == 0 || amount < stats.Min {
+			stats.Min = amount
+		}
+		if i == 0 || amount > stats.Max {
+			stats.Max = amount
+		}
+		stats.Sum += amount
+		stats.Count++
+	}
+
+	if stats.Count > 0 {
+		stats.Average = float64(stats.Sum) / float64(stats.Count)
+	}
+
+	return stats, nil
+}
+
+// QueryAssetsByAmountRange returns the assets whose amount lies between min and max, both inclusive.
+// It uses a CouchDB rich query, so it requires CouchDB as the state database.
+func (s *SmartContract) QueryAssetsByAmountRange(ctx contractapi.TransactionContextInterface, min, max int) ([]QueryResult, error) {
+	if min > max {
+		return nil, fmt.Errorf("The minimum amount %d is greater than the maximum amount %d", min, max)
+	}
+
+	selector := map[string]interface{}{
+		"selector": map[string]interface{}{
+			"amount": map[string]interface{}{
+				"$gte": min,
+				"$lte": max,
+			},
+		},
+	}
+
+	queryString, err := json.Marshal(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	return getAssetsByQuery(ctx, string(queryString))
+}
+
+// QueryAssetsOwnedByAll returns the assets co-owned by every owner in ownersJSON, a JSON array of owner names.
+// It uses a CouchDB rich query, so it requires CouchDB as the state database.
+func (s *SmartContract) QueryAssetsOwnedByAll(ctx contractapi.TransactionContextInterface, ownersJSON string) ([]QueryResult, error) {
+	var owners []string
+	err := json.Unmarshal([]byte(ownersJSON), &owners)
+	if err != nil {
+		return nil, fmt.Errorf("Owners must be a JSON array of strings. %s", err.Error())
+	}
+	if len(owners) == 0 {
+		return nil, fmt.Errorf("At least one owner is required")
+	}
+	for _, owner := range owners {
+		if strings.TrimSpace(owner) == "" {
+			return nil, fmt.Errorf("Owners must not be empty")
+		}
+	}
+
+	selector := map[string]interface{}{
+		"selector": map[string]interface{}{
+			"owners": map[string]interface{}{
+				"$all": owners,
+			},
+		},
+	}
+
+	queryString, err := json.Marshal(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	return getAssetsByQuery(ctx, string(queryString))
+}
+
+// assetFilter is one node of the filter language QueryAssetsAdvanced accepts. A node either combines
+// other nodes with "and" or "or", or compares a field with "eq" or "in".
+type assetFilter struct {
+	And   []assetFilter     `json:"and"`
+	Or    []assetFilter     `json:"or"`
+	Field string            `json:"field"`
+	Eq    json.RawMessage   `json:"eq"`
+	In    []json.RawMessage `json:"in"`
+}
+
+// QueryAssetsAdvanced returns the assets matching filterJSON, a filter such as
+//
+//	{"and": [{"or": [{"field": "owner", "eq": "A"}, {"field": "owner", "eq": "B"}]}, {"field": "registered", "eq": 1}]}
+//
+// where "and" and "or" combine filters and "eq" and "in" compare an asset field, or a "metadata."
+// entry, with a value or a list of values. It uses a CouchDB rich query, so it requires CouchDB as the state database.
+func (s *SmartContract) QueryAssetsAdvanced(ctx contractapi.TransactionContextInterface, filterJSON string) ([]QueryResult, error) {
+	decoder := json.NewDecoder(strings.NewReader(filterJSON))
+	decoder.DisallowUnknownFields()
+
+	var filter assetFilter
+	err := decoder.Decode(&filter)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse the filter. %s", err.Error())
+	}
+
+	selector, err := filterSelector(&filter)
+	if err != nil {
+		return nil, err
+	}
+
+	queryString, err := json.Marshal(map[string]interface{}{"selector": selector})
+	if err != nil {
+		return nil, err
+	}
+
+	return getAssetsByQuery(ctx, string(queryString))
+}
+
+// filterSelector translates filter into the equivalent CouchDB selector.
+func filterSelector(filter *assetFilter) (map[string]interface{}, error) {
+	operators := 0
+	for _, used := range []bool{filter.And != nil, filter.Or != nil, filter.Field != ""} {
+		if used {
+			operators++
+		}
+	}
+	if operators != 1 {
+		return nil, fmt.Errorf("Every filter needs exactly one of and, or and field")
+	}
+
+	if filter.Field == "" {
+		combinator, operands := "$and", filter.And
+		if filter.Or != nil {
+			combinator, operands = "$or", filter.Or
+		}
+		if len(operands) == 0 || filter.Eq != nil || filter.In != nil {
+			return nil, fmt.Errorf("The %s filter needs at least one operand and no comparison", combinator[1:])
+		}
+
+		selectors := []interface{}{}
+		for i := range operands {
+			selector, err := filterSelector(&operands[i])
+			if err != nil {
+				return nil, err
+			}
+			selectors = append(selectors, selector)
+		}
+
+		return map[string]interface{}{combinator: selectors}, nil
+	}
+
+	if strings.HasPrefix(filter.Field, "metadata.") {
+		err := validateMetadataKey(strings.TrimPrefix(filter.Field, "metadata."))
+		if err != nil {
+			return nil, err
+		}
+	} else if !assetFieldNames()[filter.Field] {
+		return nil, fmt.Errorf("The field %s is not an asset field", filter.Field)
+	}
+
+	switch {
+	case filter.Eq != nil && filter.In == nil:
+		return map[string]interface{}{filter.Field: map[string]interface{}{"$eq": filter.Eq}}, nil
+	case filter.In != nil && filter.Eq == nil && len(filter.In) > 0:
+		return map[string]interface{}{filter.Field: map[string]interface{}{"$in": filter.In}}, nil
+	}
+
+	return nil, fmt.Errorf("The filter on %s needs either an eq value or a non-empty in list", filter.Field)
+}
+
+// getAssetsByQuery runs a CouchDB rich query and returns the matching assets the caller may read.
+func getAssetsByQuery(ctx contractapi.TransactionContextInterface, queryString string) ([]QueryResult, error) {
+	prefix, err := tenantPrefix(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	readable, err := readableFilter(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	results := []QueryResult{}
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+
+		if err != nil {
+			return nil, err
+		}
+		id, ok := assetIDFromKey(prefix, queryResponse.Key)
+		if !ok {
+			continue
+		}
+
+		asset := new(Asset)
+		err = json.Unmarshal(queryResponse.Value, asset)
+		if err != nil {
+			return nil, err
+		}
+		migrateOwners(asset)
+
+		if readable != nil && !readable(asset) {
+			continue
+		}
+
+		queryResult := QueryResult{Key: id, Record: asset}
+		results = append(results, queryResult)
+	}
+
+	return results, nil
+}
+
+// QueryAssetsPaginated returns one page of the assets matching selectorJSON, a CouchDB selector object, starting
+// at bookmark. Pass the returned bookmark to fetch the next page. It requires CouchDB as the state database.
+// In ACL mode assets the caller may not read are left out, so a page may hold fewer than pageSize records.
+func (s *SmartContract) QueryAssetsPaginated(ctx contractapi.TransactionContextInterface, selectorJSON string, pageSize int32, bookmark string) (*PagedQueryResult, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("Page size must be greater than zero, got %d", pageSize)
+	}
+
+	var selector map[string]interface{}
+	err := json.Unmarshal([]byte(selectorJSON), &selector)
+	if err != nil {
+		return nil, fmt.Errorf("The selector must be a JSON object. %s", err.Error())
+	}
+	if selector == nil {
+		return nil, fmt.Errorf("The selector must be a JSON object")
+	}
+
+	queryString, err := json.Marshal(map[string]interface{}{"selector": selector})
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, err := tenantPrefix(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	readable, err := readableFilter(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetQueryResultWithPagination(string(queryString), pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	page := &PagedQueryResult{Records: []QueryResult{}}
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		id, ok := assetIDFromKey(prefix, queryResponse.Key)
+		if !ok {
+			continue
+		}
+
+		asset := new(Asset)
+		err = json.Unmarshal(queryResponse.Value, asset)
+		if err != nil {
+			return nil, err
+		}
+		migrateOwners(asset)
+		if readable != nil && !readable(asset) {
+			continue
+		}
+
+		page.Records = append(page.Records, QueryResult{Key: id, Record: asset})
+	}
+
+	if responseMetadata != nil {
+		page.Bookmark = responseMetadata.Bookmark
+		page.FetchedCount = responseMetadata.FetchedRecordsCount
+	}
+
+	return page, nil
+}
+
+// GetAssetFeed returns the registered assets of one page of the world state in key order, along with the
+// bookmark to pass in for the next page. Pass an empty bookmark to start from the beginning. Pages are cut
+// before filtering, so a page may hold fewer than pageSize registered assets, or none; FetchedCount tells
+// how many keys the page covered. A client that stores the bookmark can resume exactly where it stopped.
+func (s *SmartContract) GetAssetFeed(ctx contractapi.TransactionContextInterface, bookmark string, pageSize int32) (*FeedPage, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("Page size must be greater than zero, got %d", pageSize)
+	}
+
+	prefix, err := tenantPrefix(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	readable, err := readableFilter(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetStateByRangeWithPagination(prefix, prefixUpperBound(prefix), pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	page := &FeedPage{Records: []QueryResult{}}
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		id, ok := assetIDFromKey(prefix, queryResponse.Key)
+		if !ok {
+			continue
+		}
+
+		asset := new(Asset)
+		err = json.Unmarshal(queryResponse.Value, asset)
+		if err != nil {
+			return nil, err
+		}
+
+		if asset.Registered == 1 && (readable == nil || readable(asset)) {
+			page.Records = append(page.Records, QueryResult{Key: id, Record: asset})
+		}
+	}
+
+	if responseMetadata != nil {
+		page.Bookmark = responseMetadata.Bookmark
+		page.FetchedCount = responseMetadata.FetchedRecordsCount
+	}
+
+	return page, nil
+}
+
+// GetCreationHistogram returns the number of assets created per day, month or year, keyed by the UTC date
+// of the bucket, such as "2024-05-17", "2024-05" or "2024". Assets written before creation times were
+// recorded are left out.
+func (s *SmartContract) GetCreationHistogram(ctx contractapi.TransactionContextInterface, granularity string) (map[string]int, error) {
+	layout, ok := creationHistogramLayouts[granularity]
+	if !ok {
+		return nil, fmt.Errorf("Unknown granularity %s. Expected day, month or year", granularity)
+	}
+
+	assets, err := getAssetsWhere(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	histogram := make(map[string]int)
+	for _, asset := range assets {
+		if asset.Record.CreatedAt.IsZero() {
+			continue
+		}
+		histogram[asset.Record.CreatedAt.UTC().Format(layout)]++
+	}
+
+	return histogram, nil
+}
+
+// SampleAssets returns a pseudo-random sample of n assets chosen by hashing seedString with each asset ID,
+// so every peer, and every later call with the same seed over the same assets, picks the same sample.
+// When n exceeds the number of assets, all of them are returned.
+func (s *SmartContract) SampleAssets(ctx contractapi.TransactionContextInterface, n int, seedString string) ([]QueryResult, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("Sample size must not be negative, got %d", n)
+	}
+
+	assets, err := getAssetsWhere(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	scores := make(map[string]string, len(assets))
+	for _, asset := range assets {
+		sum := sha256.Sum256([]byte(seedString + "\x00" + asset.Key))
+		scores[asset.Key] = hex.EncodeToString(sum[:])
+	}
+
+	sort.Slice(assets, func(i, j int) bool {
+		return scores[assets[i].Key] < scores[assets[j].Key]
+	})
+
+	if n < len(assets) {
+		assets = assets[:n]
+	}
+
+	return assets, nil
+}
+
+// ExportAssetGraph returns the references between assets as a JSON graph, with asset IDs as nodes in key
+// order and an edge for every reference. At most maxGraphNodes assets are included; when the ledger holds
+// more, the graph is cut off and marked as truncated. With sorted set the nodes are ordered by asset ID and
+// the edges of each node by the asset they point to, so every peer exports identical bytes.
+func (s *SmartContract) ExportAssetGraph(ctx contractapi.TransactionContextInterface, sorted bool) (string, error) {
+	assets, err := getAssetsWhere(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	if sorted {
+		sortByID(assets)
+	}
+
+	graph := AssetGraph{Nodes: []string{}, Edges: []GraphEdge{}}
+
+	if len(assets) > maxGraphNodes {
+		assets = assets[:maxGraphNodes]
+		graph.Truncated = true
+	}
+
+	for _, asset := range assets {
+		graph.Nodes = append(graph.Nodes, asset.Key)
+
+		references := asset.Record.References
+		if sorted {
+			references = append([]string{}, references...)
+			sort.Strings(references)
+		}
+		for _, reference := range references {
+			graph.Edges = append(graph.Edges, GraphEdge{From: asset.Key, To: reference})
+		}
+	}
+
+	graphJSON, err := json.Marshal(graph)
+	if err != nil {
+		return "", err
+	}
+
+	return string(graphJSON), nil
+}
+
+// GetAssetsByIDPrefix returns the assets whose IDs start with prefix, in key order.
+// An empty prefix returns all assets.
+func (s *SmartContract) GetAssetsByIDPrefix(ctx contractapi.TransactionContextInterface, prefix string) ([]QueryResult, error) {
+	if prefix == "" {
+		return getAssetsWhere(ctx, nil)
+	}
+	if isReservedKey(prefix) {
+		return nil, fmt.Errorf("The prefix %q is reserved for internal use", prefix)
+	}
+
+	match, err := readableFilter(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return getAssetsInRange(ctx, prefix, prefixUpperBound(prefix), match)
+}
+
+// prefixUpperBound returns the smallest key greater than every key starting with prefix,
+// or an empty string, meaning no upper bound, when there is none.
+func prefixUpperBound(prefix string) string {
+	bound := []byte(prefix)
+	for i := len(bound) - 1; i >= 0; i-- {
+		if bound[i] < 0xff {
+			bound[i]++
+			return string(bound[:i+1])
+		}
+	}
+
+	return ""
+}
+
+// ProcessAllAssets folds every asset in world state into a summary of how many assets are in each lifecycle
+// state, reading them batchSize at a time so that only one batch is held in memory. Paginated reads are
+// only allowed in queries, so this cannot be submitted as part of an update transaction.
+func (s *SmartContract) ProcessAllAssets(ctx contractapi.TransactionContextInterface, batchSize int) (*LedgerSummary, error) {
+	if batchSize <= 0 {
+		return nil, fmt.Errorf("Batch size must be greater than zero, got %d", batchSize)
+	}
+
+	summary := &LedgerSummary{ByStatus: make(map[string]int)}
+
+	err := forEachAssetBatch(ctx, int32(batchSize), func(batch []QueryResult) error {
+		summary.Batches++
+		for _, asset := range batch {
+			summary.TotalAssets++
+			summary.ByStatus[string(currentState(asset.Record))]++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+// forEachAssetBatch pages through the assets in world state the caller may read in key order and calls
+// process with each page of at most batchSize assets. Pages are read with GetStateByRangeWithPagination, so it only works in queries.
+func forEachAssetBatch(ctx contractapi.TransactionContextInterface, batchSize int32, process func([]QueryResult) error) error {
+	prefix, err := tenantPrefix(ctx)
+	if err != nil {
+		return err
+	}
+
+	readable, err := readableFilter(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	bookmark := ""
+	for {
+		resultsIterator, responseMetadata, err := ctx.GetStub().GetStateByRangeWithPagination(prefix, prefixUpperBound(prefix), batchSize, bookmark)
+		if err != nil {
+			return err
+		}
+
+		batch := []QueryResult{}
+		for resultsIterator.HasNext() {
+			queryResponse, err := resultsIterator.Next()
+			if err != nil {
+				resultsIterator.Close()
+				return err
+			}
+			id, ok := assetIDFromKey(prefix, queryResponse.Key)
+			if !ok {
+				continue
+			}
+
+			asset := new(Asset)
+			err = json.Unmarshal(queryResponse.Value, asset)
+			if err != nil {
+				resultsIterator.Close()
+				return err
+			}
+			migrateOwners(asset)
+			if readable != nil && !readable(asset) {
+				continue
+			}
+
+			batch = append(batch, QueryResult{Key: id, Record: asset})
+		}
+		resultsIterator.Close()
+
+		if len(batch) > 0 {
+			err = process(batch)
+			if err != nil {
+				return err
+			}
+		}
+
+		if responseMetadata == nil || responseMetadata.Bookmark == "" || responseMetadata.FetchedRecordsCount < batchSize {
+			return nil
+		}
+		bookmark = responseMetadata.Bookmark
+	}
+}
+
+// getAssetsWhere scans all assets found in world state and returns those accepted by match that the caller
+// may read. A nil match returns every asset the caller may read.
+func getAssetsWhere(ctx contractapi.TransactionContextInterface, match func(*Asset) bool) ([]QueryResult, error) {
+	match, err := readableFilter(ctx, match)
+	if err != nil {
+		return nil, err
+	}
+
+	// range query with empty string for startKey and endKey does an open-ended query of all assets in the chaincode namespace.
+	return getAssetsInRange(ctx, "", "", match)
+}
+
+// getEveryAsset returns every asset found in world state, including those ACL mode hides from the caller,
+// for bookkeeping that has to cover the whole ledger.
+func getEveryAsset(ctx contractapi.TransactionContextInterface) ([]QueryResult, error) {
+	return getAssetsInRange(ctx, "", "", nil)
+}
+
+// getAssetsInRange scans the assets with IDs from startID up to, but excluding, endID and returns
+// those accepted by match. An empty endID leaves the range open and a nil match returns every asset in it.
+func getAssetsInRange(ctx contractapi.TransactionContextInterface, startID, endID string, match func(*Asset) bool) ([]QueryResult, error) {
+	results, _, err := scanAssetsInRange(ctx, startID, endID, 0, match)
+	return results, err
+}
+
+// scanAssetsInRange implements getAssetsInRange, stopping once it has found limit matching assets unless
+// limit is zero. It reports whether another matching asset follows the ones returned.
+func scanAssetsInRange(ctx contractapi.TransactionContextInterface, startID, endID string, limit int, match func(*Asset) bool) ([]QueryResult, bool, error) {
+	prefix, err := tenantPrefix(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	endKey := prefixUpperBound(prefix)
+	if endID != "" {
+		endKey = prefix + endID
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByRange(prefix+startID, endKey)
+
+	if err != nil {
+		return nil, false, err
+	}
+	defer resultsIterator.Close()
+
+	results := []QueryResult{}
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+
+		if err != nil {
+			return nil, false, err
+		}
+		id, ok := assetIDFromKey(prefix, queryResponse.Key)
+		if !ok {
+			continue
+		}
+
+		asset := new(Asset)
+		err = json.Unmarshal(queryResponse.Value, asset)
+		if err != nil {
+			return nil, false, err
+		}
+
+		migrateOwners(asset)
+
+		if match != nil && !match(asset) {
+			continue
+		}
+
+		if limit > 0 && len(results) == limit {
+			return results, true, nil
+		}
+
+		queryResult := QueryResult{Key: id, Record: asset}
+		results = append(results, queryResult)
+	}
+
+	return results, false, nil
+}
+
+// GetLedgerFingerprint returns a SHA-256 digest over all assets found in world state.
+// Each asset is re-marshaled to its canonical JSON form and fed into the hash in key order.
+// The ordering must be deterministic for peers to agree on the digest; the range query
+// returns keys in lexical order, so the same set of assets always yields the same fingerprint
+// regardless of the order in which they were written.
+func (s *SmartContract) GetLedgerFingerprint(ctx contractapi.TransactionContextInterface) (string, error) {
+	prefix, err := tenantPrefix(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByRange(prefix, prefixUpperBound(prefix))
+
+	if err != nil {
+		return "", err
+	}
+	defer resultsIterator.Close()
+
+	hash := sha256.New()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+
+		if err != nil {
+			return "", err
+		}
+		_, ok := assetIDFromKey(prefix, queryResponse.Key)
+		if !ok {
+			continue
+		}
+
+		asset := new(Asset)
+		err = json.Unmarshal(queryResponse.Value, asset)
+		if err != nil {
+			return "", err
+		}
+
+		assetJSON, err := json.Marshal(asset)
+		if err != nil {
+			return "", err
+		}
+
+		hash.Write(assetJSON)
+		hash.Write([]byte("\n"))
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// GetAssetHistoryPage returns up to limit history entries of the asset with given id, starting at offset,
+// along with the total number of entries. The whole history is read to compute the total. In ACL mode the
+// history of an asset that still exists needs read access to it.
+func (s *SmartContract) GetAssetHistoryPage(ctx contractapi.TransactionContextInterface, id string, offset, limit int) (*AssetHistoryPage, error) {
+	if offset < 0 {
+		return nil, fmt.Errorf("Offset must not be negative, got %d", offset)
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("Limit must be greater than zero, got %d", limit)
+	}
+
+	exists, err := s.AssetExists(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		_, err = s.ReadAsset(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	history, err := getAssetHistory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &AssetHistoryPage{Records: []AssetHistory{}, Total: len(history)}
+	if offset >= len(history) {
+		return page, nil
+	}
+
+	end := offset + limit
+	if end > len(history) {
+		end = len(history)
+	}
+	page.Records = history[offset:end]
+
+	return page, nil
+}
+
+// ReplayEvents reconstructs the create, update and delete events of the assets in world state from their
+// histories and returns those that follow the transaction with given txID, oldest first. An empty txID
+// replays every event. Assets that were deleted and never recreated have no key left to find their
+// history by, so their events are missing. Like GetAssetsModifiedInTx, this reads the full history of
+// every asset and holds all events in memory, so its cost grows with the length of the whole ledger.
+func (s *SmartContract) ReplayEvents(ctx contractapi.TransactionContextInterface, sinceTxID string) ([]EventRecord, error) {
+	assets, err := getAssetsWhere(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	events := []EventRecord{}
+
+	for _, asset := range assets {
+		history, err := getAssetHistory(ctx, asset.Key)
+		if err != nil {
+			return nil, err
+		}
+		sort.SliceStable(history, func(i, j int) bool {
+			return history[i].Timestamp.Before(history[j].Timestamp)
+		})
+
+		exists := false
+		for _, entry := range history {
+			event := EventRecord{ID: asset.Key, TxID: entry.TxID, Timestamp: entry.Timestamp, Record: entry.Record}
+			switch {
+			case entry.IsDelete:
+				event.Type = "delete"
+			case exists:
+				event.Type = "update"
+			default:
+				event.Type = "create"
+			}
+			exists = !entry.IsDelete
+
+			events = append(events, event)
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].Timestamp.Equal(events[j].Timestamp) {
+			return events[i].Timestamp.Before(events[j].Timestamp)
+		}
+		if events[i].TxID != events[j].TxID {
+			return events[i].TxID < events[j].TxID
+		}
+
+		return events[i].ID < events[j].ID
+	})
+
+	if sinceTxID == "" {
+		return events, nil
+	}
+
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].TxID == sinceTxID {
+			return events[i+1:], nil
+		}
+	}
+
+	return nil, fmt.Errorf("No asset was written by transaction %s", sinceTxID)
+}
+
+// GetAssetsModifiedInTx returns the assets written by the transaction with given txID, each with its value
+// as of that transaction. This reads the full history of every asset in world state, so its cost grows with
+// both the number of assets and the length of their histories; it is meant for debugging, not routine use.
+func (s *SmartContract) GetAssetsModifiedInTx(ctx contractapi.TransactionContextInterface, txID string) ([]QueryResult, error) {
+	assets, err := getAssetsWhere(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []QueryResult{}
+
+	for _, asset := range assets {
+		history, err := getAssetHistory(ctx, asset.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range history {
+			if entry.TxID == txID && !entry.IsDelete {
+				results = append(results, QueryResult{Key: asset.Key, Record: entry.Record})
+				break
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// GetAssetTimeline returns everything that happened to the asset with given id in chronological order,
+// merging its state history with its approval log. State changes are typed "created", "updated" or "deleted",
+// approvals carry their approval type. Events with the same timestamp are ordered by txID, and within a
+// transaction the state change comes before the approval that caused it.
+func (s *SmartContract) GetAssetTimeline(ctx contractapi.TransactionContextInterface, id string) ([]TimelineEvent, error) {
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := getAssetHistory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(history, func(i, j int) bool {
+		return historyEntryBefore(history[i].Timestamp, history[i].TxID, history[j].Timestamp, history[j].TxID)
+	})
+
+	timeline := []TimelineEvent{}
+
+	deleted := true
+	for _, entry := range history {
+		eventType := "updated"
+		switch {
+		case entry.IsDelete:
+			eventType = "deleted"
+		case deleted:
+			eventType = "created"
+		}
+		deleted = entry.IsDelete
+
+		timeline = append(timeline, TimelineEvent{Type: eventType, TxID: entry.TxID, Timestamp: entry.Timestamp, Record: entry.Record})
+	}
+
+	for i := range asset.ApprovalLog {
+		approval := asset.ApprovalLog[i]
+		timeline = append(timeline, TimelineEvent{Type: approval.ApprovalType, TxID: approval.TxID, Timestamp: approval.Timestamp, Approval: &approval})
+	}
+
+	// the stable sort keeps state changes ahead of approvals within the same transaction
+	sort.SliceStable(timeline, func(i, j int) bool {
+		return historyEntryBefore(timeline[i].Timestamp, timeline[i].TxID, timeline[j].Timestamp, timeline[j].TxID)
+	})
+
+	return timeline, nil
+}
+
+// GetOwnershipDurations returns the periods in which the asset with given id was held by each of its owners,
+// oldest first. The current owner's period ends at the time of this transaction. Periods cut short by a
+// delete end at the delete, and the asset's history restarts when it is recreated.
+func (s *SmartContract) GetOwnershipDurations(ctx contractapi.TransactionContextInterface, id string) ([]OwnershipDuration, error) {
+	// reading the asset checks that it exists and, in ACL mode, that the caller may read it
+	_, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := getAssetHistory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(history, func(i, j int) bool {
+		return historyEntryBefore(history[i].Timestamp, history[i].TxID, history[j].Timestamp, history[j].TxID)
+	})
+
+	durations := []OwnershipDuration{}
+	var current *OwnershipDuration
+
+	closePeriod := func(end time.Time) {
+		if current == nil {
+			return
+		}
+		current.End = end
+		current.DurationSeconds = int64(end.Sub(current.Start) / time.Second)
+		durations = append(durations, *current)
+		current = nil
+	}
+
+	for _, entry := range history {
+		if entry.IsDelete {
+			closePeriod(entry.Timestamp)
+			continue
+		}
+		if current != nil && current.Owner == entry.Record.Owner {
+			continue
+		}
+
+		closePeriod(entry.Timestamp)
+		current = &OwnershipDuration{Owner: entry.Record.Owner, Start: entry.Timestamp}
+	}
+
+	if current != nil {
+		now, err := getTxTime(ctx)
+		if err != nil {
+			return nil, err
+		}
+		closePeriod(now)
+		durations[len(durations)-1].Current = true
+	}
+
+	return durations, nil
+}
+
+// historyEntryBefore orders entries by timestamp, breaking ties by txID.
+func historyEntryBefore(timestampA time.Time, txIDA string, timestampB time.Time, txIDB string) bool {
+	if !timestampA.Equal(timestampB) {
+		return timestampA.Before(timestampB)
+	}
+
+	return txIDA < txIDB
+}
+
+// MigrateAssets backfills the fields that records written by earlier versions of the chaincode lack and
+// returns how many assets it rewrote. Creation and registration times are taken from the asset history,
+// the status is derived from the approval flags and the owners list and checksum are filled in.
+// Assets that need no backfill are left untouched, so running it again is safe. Only admins may migrate.
+func (s *SmartContract) MigrateAssets(ctx contractapi.TransactionContextInterface) (int, error) {
+	err := requireAdmin(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	assets, err := getEveryAsset(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	migrated := 0
+	for _, result := range assets {
+		key, err := assetKey(ctx, result.Key)
+		if err != nil {
+			return 0, err
+		}
+
+		storedJSON, err := ctx.GetStub().GetState(key)
+		if err != nil {
+			return 0, fmt.Errorf("Failed to read from world state. %s", err.Error())
+		}
+
+		stored := new(Asset)
+		err = json.Unmarshal(storedJSON, stored)
+		if err != nil {
+			return 0, err
+		}
+
+		asset := *result.Record
+		err = backfillAsset(ctx, &asset)
+		if err != nil {
+			return 0, err
+		}
+
+		if stored.Checksum != "" && reflect.DeepEqual(stored, &asset) {
+			continue
+		}
+
+		err = putAsset(ctx, &asset)
+		if err != nil {
+			return 0, err
+		}
+		migrated++
+	}
+
+	return migrated, nil
+}
+
+// backfillAsset fills in the fields of asset that records written by earlier versions of the chaincode lack.
+func backfillAsset(ctx contractapi.TransactionContextInterface, asset *Asset) error {
+	migrateOwners(asset)
+	if asset.Status == "" {
+		asset.Status = stateFromFlags(asset, StateDraft)
+	}
+
+	if !asset.CreatedAt.IsZero() && (asset.Registered != 1 || !asset.RegisteredAt.IsZero()) {
+		return nil
+	}
+
+	history, err := getAssetHistory(ctx, asset.ID)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].Timestamp.Before(history[j].Timestamp)
+	})
+
+	// only the versions since the asset was last deleted belong to it
+	var createdAt, registeredAt time.Time
+	for _, entry := range history {
+		if entry.IsDelete {
+			createdAt, registeredAt = time.Time{}, time.Time{}
+			continue
+		}
+		if createdAt.IsZero() {
+			createdAt = entry.Timestamp
+		}
+		if entry.Record.Registered != 1 {
+			registeredAt = time.Time{}
+		} else if registeredAt.IsZero() {
+			registeredAt = entry.Timestamp
+		}
+	}
+
+	if asset.CreatedAt.IsZero() {
+		asset.CreatedAt = createdAt
+	}
+	if asset.Registered == 1 && asset.RegisteredAt.IsZero() {
+		asset.RegisteredAt = registeredAt
+	}
+
+	return nil
+}
+
+// getAssetHistory drains the history iterator of the asset with given id, in the order returned by the peer.
+func getAssetHistory(ctx contractapi.TransactionContextInterface, id string) ([]AssetHistory, error) {
+	key, err := assetKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read history from world state. %s", err.Error())
+	}
+	defer resultsIterator.Close()
+
+	history := []AssetHistory{}
+
+	for resultsIterator.HasNext() {
+		modification, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		entry := AssetHistory{
+			TxID:     modification.TxId,
+			IsDelete: modification.IsDelete,
+		}
+		if modification.Timestamp != nil {
+			entry.Timestamp = time.Unix(modification.Timestamp.Seconds, int64(modification.Timestamp.Nanos)).UTC()
+		}
+
+		if !modification.IsDelete {
+			asset := new(Asset)
+			err = json.Unmarshal(modification.Value, asset)
+			if err != nil {
+				return nil, err
+			}
+			entry.Record = asset
+		}
+
+		history = append(history, entry)
+	}
+
+	return history, nil
+}
+
+// stampRegistration records the transaction time and the two MSPs that approved it on asset when it becomes
+// registered, and clears both when the registration is withdrawn.
+func stampRegistration(ctx contractapi.TransactionContextInterface, previous, asset *Asset) error {
+	if asset.Registered != 1 {
+		asset.RegisteredAt = time.Time{}
+		asset.RegisteredByMSPs = [2]string{}
+		return nil
+	}
+	if previous.Registered == 1 && !asset.RegisteredAt.IsZero() {
+		return nil
+	}
+
+	registeredAt, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	asset.RegisteredAt = registeredAt
+
+	callerID, err := getClientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+
+	// an approval without a log entry since the last reset was granted by whoever set the flag directly
+	for i, approvalType := range []string{"approvalOne", "approvalTwo"} {
+		asset.RegisteredByMSPs[i] = approverSinceReset(asset, approvalType)
+		if asset.RegisteredByMSPs[i] == "" {
+			asset.RegisteredByMSPs[i] = callerID
+		}
+	}
+
+	return nil
+}
+
+// approverSinceReset returns the approver of the most recent approval of the given type in the approval log of
+// asset, ignoring approvals withdrawn by a later reset.
+func approverSinceReset(asset *Asset, approvalType string) string {
+	for i := len(asset.ApprovalLog) - 1; i >= 0; i-- {
+		switch asset.ApprovalLog[i].ApprovalType {
+		case "reset":
+			return ""
+		case approvalType:
+			return asset.ApprovalLog[i].ApproverMSP
+		}
+	}
+
+	return ""
+}
+
+// putAsset runs the asset validators, stamps the asset with its update time and a fresh checksum and writes it
+// to the world state, keeping the secondary indexes in step with the previously stored version.
+func putAsset(ctx contractapi.TransactionContextInterface, asset *Asset) error {
+	_, err := storeAsset(ctx, asset, false)
+	return err
+}
+
+// putAssetIfChanged is putAsset for transactions that may leave the asset as it is. An asset whose content
+// matches the stored version keeps its update time and checksum and is not written again, so that no-op
+// updates leave no history entry. It reports whether the asset was written.
+func putAssetIfChanged(ctx contractapi.TransactionContextInterface, asset *Asset) (bool, error) {
+	return storeAsset(ctx, asset, true)
+}
+
+// storeAsset implements putAsset and putAssetIfChanged.
+func storeAsset(ctx contractapi.TransactionContextInterface, asset *Asset, onlyIfChanged bool) (bool, error) {
+	err := requireWritable(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	key, err := assetKey(ctx, asset.ID)
+	if err != nil {
+		return false, err
+	}
+
+	previousJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	previous := new(Asset)
+	if previousJSON != nil {
+		err = json.Unmarshal(previousJSON, previous)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = validateAsset(ctx, previous, asset)
+	if err != nil {
+		return false, err
+	}
+
+	err = updateIndexes(ctx, previous, asset)
+	if err != nil {
+		return false, err
+	}
+
+	syncOwners(asset, previous.Owner)
+
+	err = stampRegistration(ctx, previous, asset)
+	if err != nil {
+		return false, err
+	}
+
+	unchanged := false
+	if onlyIfChanged && previousJSON != nil {
+		unchanged, err = sameAssetContent(previous, asset)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if unchanged {
+		// encoding the asset with the stored update time reproduces the stored bytes, which putIfChanged skips
+		asset.UpdatedAt = previous.UpdatedAt
+	} else {
+		asset.UpdatedAt, err = getTxTime(ctx)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	assetJSON, err := encodeAsset(asset)
+	if err != nil {
+		return false, err
+	}
+
+	if onlyIfChanged {
+		return putIfChanged(ctx, key, assetJSON)
+	}
+
+	err = ctx.GetStub().PutState(key, assetJSON)
+	if err != nil {
+		return false, fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return true, nil
+}
+
+// encodeAsset stamps asset with the checksum of its content and returns its JSON encoding.
+func encodeAsset(asset *Asset) ([]byte, error) {
+	asset.Checksum = ""
+
+	assetJSON, err := json.Marshal(asset)
+	if err != nil {
+		return nil, err
+	}
+
+	checksum, err := assetChecksum(assetJSON)
+	if err != nil {
+		return nil, err
+	}
+	asset.Checksum = checksum
+
+	return json.Marshal(asset)
+}
+
+// sameAssetContent reports whether a and b are equal apart from their update time and checksum.
+func sameAssetContent(a, b *Asset) (bool, error) {
+	first, second := *a, *b
+	first.UpdatedAt, second.UpdatedAt = time.Time{}, time.Time{}
+	first.Checksum, second.Checksum = "", ""
+
+	firstJSON, err := json.Marshal(first)
+	if err != nil {
+		return false, err
+	}
+
+	secondJSON, err := json.Marshal(second)
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(firstJSON, secondJSON), nil
+}
+
+// putIfChanged writes newBytes under key unless the world state already holds exactly those bytes there,
+// and reports whether it wrote them.
+func putIfChanged(ctx contractapi.TransactionContextInterface, key string, newBytes []byte) (bool, error) {
+	currentBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+	if currentBytes != nil && bytes.Equal(currentBytes, newBytes) {
+		return false, nil
+	}
+
+	err = ctx.GetStub().PutState(key, newBytes)
+	if err != nil {
+		return false, fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return true, nil
+}
+
+// updateIndexes moves the index entries of an asset from its previous version to the new one.
+// previous is a zero Asset when the asset is new, and asset is a zero Asset when it is deleted.
+func updateIndexes(ctx contractapi.TransactionContextInterface, previous, asset *Asset) error {
+	previousEntries := assetIndexEntries(previous)
+	entries := assetIndexEntries(asset)
+
+	for _, index := range secondaryIndexes {
+		if previousEntries[index] == entries[index] {
+			continue
+		}
+
+		if previousEntries[index] != "" {
+			err := deleteIndexEntry(ctx, index, previousEntries[index], previous.ID)
+			if err != nil {
+				return err
+			}
+		}
+		if entries[index] != "" {
+			err := putIndexEntry(ctx, index, entries[index], asset.ID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// VerifyIndexes cross-checks every secondary index entry against the live assets and reports the orphans:
+// entries naming an asset that no longer exists, or mapping a value the asset no longer has.
+func (s *SmartContract) VerifyIndexes(ctx contractapi.TransactionContextInterface) (*IndexReport, error) {
+	return checkIndexes(ctx)
+}
+
+// RepairIndexes removes the orphaned secondary index entries VerifyIndexes reports and returns how many it
+// removed. Only admins may repair the indexes.
+func (s *SmartContract) RepairIndexes(ctx contractapi.TransactionContextInterface) (int, error) {
+	err := requireAdmin(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	report, err := checkIndexes(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, entry := range report.Orphans {
+		err = deleteIndexEntry(ctx, entry.Index, entry.Value, entry.ID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(report.Orphans), nil
+}
+
+// checkIndexes walks the entries of every secondary index that belong to the caller's assets and collects
+// those that do not match what assetIndexEntries derives from the live asset.
+func checkIndexes(ctx contractapi.TransactionContextInterface) (*IndexReport, error) {
+	prefix, err := tenantPrefix(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	assets, err := getEveryAsset(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	expected := make(map[string]map[string]string)
+	for _, asset := range assets {
+		expected[asset.Key] = assetIndexEntries(asset.Record)
+	}
+
+	report := &IndexReport{Orphans: []IndexEntry{}}
+
+	for _, index := range secondaryIndexes {
+		resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(index, []string{})
+		if err != nil {
+			return nil, err
+		}
+
+		for resultsIterator.HasNext() {
+			queryResponse, err := resultsIterator.Next()
+			if err != nil {
+				resultsIterator.Close()
+				return nil, err
+			}
+
+			_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+			if err != nil {
+				resultsIterator.Close()
+				return nil, err
+			}
+			if len(attributes) != 2 {
+				continue
+			}
+
+			id, ok := assetIDFromKey(prefix, attributes[1])
+			if !ok {
+				continue
+			}
+			report.Checked++
+
+			entries, exists := expected[id]
+			if !exists || entries[index] != attributes[0] {
+				report.Orphans = append(report.Orphans, IndexEntry{Index: index, Value: attributes[0], ID: id})
+			}
+		}
+		resultsIterator.Close()
+	}
+
+	return report, nil
+}
+
+// assetIndexEntries returns the value each secondary index maps to asset. Indexes the asset is not in are left out.
+func assetIndexEntries(asset *Asset) map[string]string {
+	entries := make(map[string]string)
+	if asset.ID == "" {
+		return entries
+	}
+
+	entries[lowerIDIndex] = strings.ToLower(asset.ID)
+	if asset.ExternalRef != "" {
+		entries[extRefIndex] = asset.ExternalRef
+	}
+
+	return entries
+}
+
+// putIndexEntry writes the composite key index entry mapping value to the asset with given id.
+// Entries name the world state key of the asset, which keeps the assets of different tenants apart.
+func putIndexEntry(ctx contractapi.TransactionContextInterface, index, value, id string) error {
+	err := requireWritable(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, err := assetKey(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	indexKey, err := ctx.GetStub().CreateCompositeKey(index, []string{value, key})
+	if err != nil {
+		return err
+	}
+
+	// only the key is needed, a zero byte keeps the value from being empty, which would delete the entry
+	return ctx.GetStub().PutState(indexKey, []byte{0x00})
+}
+
+// deleteIndexEntry removes the composite key index entry mapping value to the asset with given id.
+func deleteIndexEntry(ctx contractapi.TransactionContextInterface, index, value, id string) error {
+	err := requireWritable(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, err := assetKey(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	indexKey, err := ctx.GetStub().CreateCompositeKey(index, []string{value, key})
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().DelState(indexKey)
+}
+
+// getIndexedIDs returns the IDs of the caller's assets the given index maps value to.
+func getIndexedIDs(ctx contractapi.TransactionContextInterface, index, value string) ([]string, error) {
+	prefix, err := tenantPrefix(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(index, []string{value})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	ids := []string{}
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		id, ok := assetIDFromKey(prefix, attributes[1])
+		if ok {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+// predatesChecksums reports whether asset was written before checksums were introduced. Every write since
+// then also fills in the status, owners and creation and update times, so a record holding any of them
+// was written with a checksum.
+func predatesChecksums(asset *Asset) bool {
+	return asset.Status == "" && len(asset.Owners) == 0 && asset.CreatedAt.IsZero() && asset.UpdatedAt.IsZero()
+}
+
+// assetChecksum returns the SHA-256 of an asset's JSON document with the checksum field left out.
+// The hash is taken over the stored document rather than the Asset struct, so records keep verifying
+// after fields are added to the struct.
+func assetChecksum(assetJSON []byte) (string, error) {
+	var document map[string]interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(assetJSON))
+	decoder.UseNumber()
+	err := decoder.Decode(&document)
+	if err != nil {
+		return "", err
+	}
+	delete(document, "checksum")
+
+	// maps are marshaled with sorted keys, giving a canonical form of the document
+	canonicalJSON, err := json.Marshal(document)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(canonicalJSON)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// isReservedKey reports whether key falls under one of the reserved internal key prefixes.
+func isReservedKey(key string) bool {
+	for _, prefix := range reservedKeyPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// emitEvent sets the chaincode event of the transaction to name with payload encoded as JSON.
+// Transactions emit their event as their last step and return any failure to do so, which fails the
+// whole transaction: Fabric then discards every write the transaction made, so a change is never
+// committed without its event. A transaction carries at most one event; setting another replaces it.
+// The payload is wrapped in an EventEnvelope. Its fields are also kept at the top level, as they were before
+// the envelope was introduced, unless they clash with a field of the envelope.
+func emitEvent(ctx contractapi.TransactionContextInterface, name string, payload interface{}) error {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	timestamp, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	envelopeJSON, err := json.Marshal(EventEnvelope{
+		EventType:     name,
+		SchemaVersion: eventSchemaVersion,
+		TxID:          ctx.GetStub().GetTxID(),
+		Timestamp:     timestamp,
+		Data:          payloadJSON,
+	})
+	if err != nil {
+		return err
+	}
+
+	fields := map[string]json.RawMessage{}
+	err = json.Unmarshal(payloadJSON, &fields)
+	if err != nil {
+		return fmt.Errorf("The payload of event %s is not a JSON object. %s", name, err.Error())
+	}
+
+	envelope := map[string]json.RawMessage{}
+	err = json.Unmarshal(envelopeJSON, &envelope)
+	if err != nil {
+		return err
+	}
+	for field, value := range envelope {
+		fields[field] = value
+	}
+
+	eventJSON, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().SetEvent(name, eventJSON)
+	if err != nil {
+		return fmt.Errorf("Failed to set event %s, rejecting the transaction. %s", name, err.Error())
+	}
+
+	return nil
+}
+
+// getTxTime returns the timestamp of the current transaction as a UTC time.
+func getTxTime(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Failed to get transaction timestamp. %s", err.Error())
+	}
+
+	return time.Unix(timestamp.Seconds, int64(timestamp.Nanos)).UTC(), nil
+}
+
+// getClientMSPID returns the MSP ID of the client submitting the transaction.
+func getClientMSPID(ctx contractapi.TransactionContextInterface) (string, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", fmt.Errorf("Failed to get client MSP ID. %s", err.Error())
+	}
+
+	return mspID, nil
+}
+
+// requireOwnerOrAdmin returns an error unless the caller owns asset or carries the admin attribute.
+func requireOwnerOrAdmin(ctx contractapi.TransactionContextInterface, asset *Asset) error {
+	callerID, err := getClientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	if callerID == asset.Owner {
+		return nil
+	}
+
+	admin, err := hasAdminAttribute(ctx)
+	if err != nil {
+		return err
+	}
+	if !admin {
+		return fmt.Errorf("Client %s is not authorized to modify asset %s", callerID, asset.ID)
+	}
+
+	return nil
+}
+
+// containsString reports whether list holds value.
+func containsString(list []string, value string) bool {
+	for _, element := range list {
+		if element == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetChaincodeInfo returns the version of the installed chaincode, the asset and event schema versions it
+// writes and the feature toggles enabled on this channel, so deployments can be compared when debugging.
+func (s *SmartContract) GetChaincodeInfo(ctx contractapi.TransactionContextInterface) (*ChaincodeInfo, error) {
+	info := &ChaincodeInfo{
+		Version:            chaincodeVersion,
+		AssetSchemaVersion: assetSchemaVersion,
+		EventSchemaVersion: eventSchemaVersion,
+		EnabledFeatures:    []string{},
+	}
+
+	for _, toggle := range featureToggles {
+		enabled, err := getConfigBool(ctx, toggle)
+		if err != nil {
+			return nil, err
+		}
+		if enabled {
+			info.EnabledFeatures = append(info.EnabledFeatures, toggle)
+		}
+	}
+
+	return info, nil
+}
+
+// SetStrictMode makes invoking a function the chaincode does not define fail with an error listing the
+// functions it does define, instead of the bare "not found" message. Only admins may change it.
+func (s *SmartContract) SetStrictMode(ctx contractapi.TransactionContextInterface, enabled bool) error {
+	return setConfigBool(ctx, strictModeConfig, enabled)
+}
+
+// unknownTransaction handles invocations of functions the contract does not define.
+func (s *SmartContract) unknownTransaction(ctx contractapi.TransactionContextInterface) error {
+	function, _ := ctx.GetStub().GetFunctionAndParameters()
+	if i := strings.LastIndex(function, ":"); i != -1 {
+		function = function[i+1:]
+	}
+
+	strict, err := getConfigBool(ctx, strictModeConfig)
+	if err != nil {
+		return err
+	}
+	if !strict {
+		return fmt.Errorf("Function %s not found", function)
+	}
+
+	return fmt.Errorf("Function %s not found. Available functions: %s", function, strings.Join(transactionNames(), ", "))
+}
+
+// transactionNames returns the sorted names of the transactions the contract defines, leaving out the
+// methods inherited from contractapi.Contract.
+func transactionNames() []string {
+	inherited := reflect.TypeOf(new(contractapi.Contract))
+	contractType := reflect.TypeOf(new(SmartContract))
+
+	names := []string{}
+	for i := 0; i < contractType.NumMethod(); i++ {
+		name := contractType.Method(i).Name
+		if _, ok := inherited.MethodByName(name); !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func main() {
+
+	contract := new(SmartContract)
+	contract.UnknownTransaction = contract.unknownTransaction
 
-	chaincode, err := contractapi.NewChaincode(new(SmartContract))
+	chaincode, err := contractapi.NewChaincode(contract)
 
 	if err != nil {
 		fmt.Printf("Error create asset-transfer-basic chaincode: %s", err.Error())
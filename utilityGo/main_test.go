@@ -0,0 +1,3628 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// mustCreateAsset creates a draft asset and fails the test when that does not succeed.
+func mustCreateAsset(t *testing.T, ctx contractapi.TransactionContextInterface, id, description, owner string) {
+	t.Helper()
+
+	err := new(SmartContract).CreateAsset(ctx, id, description, owner, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("CreateAsset(%s): %s", id, err)
+	}
+}
+
+func TestNewChaincode(t *testing.T) {
+	_, err := contractapi.NewChaincode(new(SmartContract))
+	if err != nil {
+		t.Fatalf("The contract does not build into a chaincode: %s", err)
+	}
+}
+
+func TestGetLedgerFingerprintIgnoresInsertionOrder(t *testing.T) {
+	s := new(SmartContract)
+	first, second := newTestStub(), newTestStub()
+	firstCtx, secondCtx := newTestContext(first, "Org1MSP", admin), newTestContext(second, "Org1MSP", admin)
+
+	mustCreateAsset(t, firstCtx, "asset1", "first", "Org1MSP")
+	mustCreateAsset(t, firstCtx, "asset2", "second", "Org1MSP")
+	mustCreateAsset(t, secondCtx, "asset2", "second", "Org1MSP")
+	mustCreateAsset(t, secondCtx, "asset1", "first", "Org1MSP")
+
+	want, err := s.GetLedgerFingerprint(firstCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetLedgerFingerprint(secondCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("The fingerprints differ: %s and %s", want, got)
+	}
+
+	err = s.UpdateAsset(secondCtx, "asset1", "changed", "Org1MSP", 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed, err := s.GetLedgerFingerprint(secondCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed == want {
+		t.Error("The fingerprint did not change with an asset")
+	}
+}
+
+func TestCreatedByMSPSurvivesUpdate(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	creator, updater := newTestContext(stub, "Org1MSP", admin), newTestContext(stub, "Org2MSP", admin)
+
+	mustCreateAsset(t, creator, "asset1", "first", "Org1MSP")
+	err := s.UpdateAsset(updater, "asset1", "changed", "Org1MSP", 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := s.GetAssetsCreatedBy(updater, "Org1MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Record.CreatedByMSP != "Org1MSP" || results[0].Record.Description != "changed" {
+		t.Errorf("Unexpected assets created by Org1MSP: %+v", results)
+	}
+
+	results, err = s.GetAssetsCreatedBy(updater, "Org2MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("The update rewrote the creator: %+v", results)
+	}
+}
+
+func TestGetAssetHistoryPageSlicesHistory(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+
+	mustCreateAsset(t, ctx, "asset1", "version0", "Org1MSP")
+	for i := 1; i < 10; i++ {
+		stub.begin(fmt.Sprintf("tx%d", i))
+		err := s.UpdateAsset(ctx, "asset1", fmt.Sprintf("version%d", i), "Org1MSP", 0, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	page, err := s.GetAssetHistoryPage(ctx, "asset1", 3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 10 || len(page.Records) != 4 {
+		t.Fatalf("Expected 4 of 10 versions, got %d of %d", len(page.Records), page.Total)
+	}
+	for i, record := range page.Records {
+		if want := fmt.Sprintf("version%d", i+3); record.Record.Description != want {
+			t.Errorf("Record %d holds %s, expected %s", i, record.Record.Description, want)
+		}
+	}
+
+	page, err = s.GetAssetHistoryPage(ctx, "asset1", 8, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 10 || len(page.Records) != 2 {
+		t.Errorf("Expected the last 2 of 10 versions, got %d of %d", len(page.Records), page.Total)
+	}
+
+	page, err = s.GetAssetHistoryPage(ctx, "asset1", 10, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Records) != 0 {
+		t.Errorf("Expected an empty page past the end, got %d versions", len(page.Records))
+	}
+}
+
+func TestGetAssetHistoryPageRejectsBadBounds(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "version0", "Org1MSP")
+
+	_, err := s.GetAssetHistoryPage(ctx, "asset1", -1, 4)
+	if err == nil {
+		t.Error("A negative offset was accepted")
+	}
+	_, err = s.GetAssetHistoryPage(ctx, "asset1", 0, 0)
+	if err == nil {
+		t.Error("A zero limit was accepted")
+	}
+}
+
+func TestRegisterAssetDirectlyAsAdmin(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+
+	err := s.RegisterAssetDirectly(ctx, "asset1", "first", "Org1MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	asset, err := s.ReadAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.ApprovalOne != 1 || asset.ApprovalTwo != 1 || asset.Registered != 1 {
+		t.Errorf("The asset is not registered: %+v", asset)
+	}
+	if stub.events["AssetRegistered"] == nil {
+		t.Error("No AssetRegistered event was emitted")
+	}
+}
+
+func TestRegisterAssetDirectlyRejectsNonAdmin(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org2MSP", admin)
+
+	err := s.RegisterAssetDirectly(ctx, "asset1", "first", "Org2MSP")
+	if err == nil {
+		t.Fatal("A client outside the admin MSP registered an asset directly")
+	}
+
+	exists, err := s.AssetExists(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Error("The rejected asset was written")
+	}
+}
+
+func TestGetApprovalProgress(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+
+	err := s.SubmitForApproval(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkApprovalProgress(t, s, ctx, ApprovalProgress{ApprovalsGranted: 0, ApprovalsRequired: 2, Percentage: 0, Stage: "awaiting first approval"})
+
+	err = s.ApproveRequestOne(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkApprovalProgress(t, s, ctx, ApprovalProgress{ApprovalsGranted: 1, ApprovalsRequired: 2, Percentage: 50, Stage: "awaiting second approval"})
+
+	err = s.ApproveRequestTwo(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkApprovalProgress(t, s, ctx, ApprovalProgress{ApprovalsGranted: 2, ApprovalsRequired: 2, Percentage: 100, Stage: "registered"})
+}
+
+func checkApprovalProgress(t *testing.T, s *SmartContract, ctx contractapi.TransactionContextInterface, want ApprovalProgress) {
+	t.Helper()
+
+	progress, err := s.GetApprovalProgress(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *progress != want {
+		t.Errorf("Expected progress %+v, got %+v", want, *progress)
+	}
+}
+
+func TestCreateAssetRejectsReservedIDs(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+
+	for _, id := range []string{internalKeyPrefix + "config", compositeKeyNamespace + "index"} {
+		err := s.CreateAsset(ctx, id, "first", "Org1MSP", 0, 0, 0)
+		if err == nil {
+			t.Errorf("The reserved ID %q was accepted", id)
+		}
+	}
+
+	err := s.CreateAsset(ctx, "asset-1", "first", "Org1MSP", 0, 0, 0)
+	if err != nil {
+		t.Errorf("A normal ID was rejected: %s", err)
+	}
+}
+
+func TestGetAssetsModifiedInTx(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+
+	stub.begin("shared")
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+	mustCreateAsset(t, ctx, "asset2", "second", "Org1MSP")
+	stub.begin("other")
+	mustCreateAsset(t, ctx, "asset3", "third", "Org1MSP")
+	err := s.UpdateAsset(ctx, "asset1", "changed", "Org1MSP", 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := s.GetAssetsModifiedInTx(ctx, "shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected the 2 assets written by the transaction, got %+v", results)
+	}
+	for _, result := range results {
+		if result.Key != "asset1" && result.Key != "asset2" {
+			t.Errorf("Unexpected asset %s", result.Key)
+		}
+		if result.Key == "asset1" && result.Record.Description != "first" {
+			t.Errorf("Expected the value written by the transaction, got %s", result.Record.Description)
+		}
+	}
+}
+
+func TestCompareAndSwapField(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+
+	swapped, err := s.CompareAndSwapField(ctx, "asset1", "description", "other", "changed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if swapped {
+		t.Error("The field was swapped although it did not hold the expected value")
+	}
+
+	swapped, err = s.CompareAndSwapField(ctx, "asset1", "description", "first", "changed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !swapped {
+		t.Error("The field was not swapped although it held the expected value")
+	}
+
+	asset, err := s.ReadAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Description != "changed" {
+		t.Errorf("Expected the new description, got %s", asset.Description)
+	}
+
+	_, err = s.CompareAndSwapField(ctx, "asset1", "ID", "asset1", "asset2")
+	if err == nil {
+		t.Error("A field outside the swappable ones was accepted")
+	}
+}
+
+func TestGroupAssetsByOwner(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+	mustCreateAsset(t, ctx, "asset2", "second", "Org2MSP")
+	mustCreateAsset(t, ctx, "asset3", "third", "Org1MSP")
+	mustCreateAsset(t, ctx, "asset4", "fourth", "Org3MSP")
+
+	groups, err := s.GroupAssetsByOwner(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]int{"Org1MSP": 2, "Org2MSP": 1, "Org3MSP": 1}
+	if len(groups) != len(want) {
+		t.Fatalf("Expected %d owners, got %d", len(want), len(groups))
+	}
+	for owner, count := range want {
+		if len(groups[owner]) != count {
+			t.Errorf("Expected %d assets of %s, got %d", count, owner, len(groups[owner]))
+		}
+		for _, result := range groups[owner] {
+			if result.Record.Owner != owner {
+				t.Errorf("The asset %s of %s is grouped under %s", result.Key, result.Record.Owner, owner)
+			}
+		}
+	}
+}
+
+func TestCreateAssetDefaultsOwnerToCaller(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org2MSP", member)
+
+	err := s.CreateAsset(ctx, "asset1", "first", "", 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	asset, err := s.ReadAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Owner != "Org2MSP" {
+		t.Errorf("Expected the caller to own the asset, got %s", asset.Owner)
+	}
+}
+
+func TestCreateAssetRejectsOtherOwnerWithoutAdmin(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+
+	err := s.CreateAsset(newTestContext(stub, "Org2MSP", member), "asset1", "first", "Org1MSP", 0, 0, 0)
+	if err == nil {
+		t.Error("A client without the admin attribute created an asset for another owner")
+	}
+
+	err = s.CreateAsset(newTestContext(stub, "Org2MSP", admin), "asset1", "first", "Org1MSP", 0, 0, 0)
+	if err != nil {
+		t.Errorf("An admin could not create an asset for another owner: %s", err)
+	}
+}
+
+func TestReadAssetVerifiesChecksum(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+
+	asset, err := s.ReadAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatalf("A clean record failed to read: %s", err)
+	}
+	if asset.Checksum == "" {
+		t.Error("No checksum was stored")
+	}
+
+	tampered := *asset
+	tampered.Owner = "Org2MSP"
+	tamperedJSON, err := json.Marshal(tampered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = stub.MockStub.PutState("asset1", tamperedJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.ReadAsset(ctx, "asset1")
+	if err == nil {
+		t.Error("A tampered record was read without error")
+	}
+}
+
+func TestReadAssetRejectsMissingChecksum(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+	asset, err := s.ReadAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := *asset
+	tampered.Owner = "Org2MSP"
+	tampered.Checksum = ""
+	tamperedJSON, err := json.Marshal(tampered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = stub.MockStub.PutState("asset1", tamperedJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.ReadAsset(ctx, "asset1")
+	if err == nil || !strings.Contains(err.Error(), "checksum is missing") {
+		t.Errorf("Expected a record stripped of its checksum to be reported as corrupted, got %v", err)
+	}
+
+	err = stub.MockStub.PutState("legacy", []byte(`{"ID":"legacy","description":"old","owner":"Org1MSP"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.ReadAsset(ctx, "legacy")
+	if err != nil {
+		t.Errorf("Expected a record predating checksums to be read, got %s", err)
+	}
+}
+
+func TestLifecycleAcceptsLegalSequence(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+
+	checkAllowedTransitions(t, s, ctx, "submitted,rejected")
+
+	for _, step := range []func(contractapi.TransactionContextInterface, string) error{s.SubmitForApproval, s.ApproveRequestOne, s.ApproveRequestTwo} {
+		err := step(ctx, "asset1")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	asset, err := s.ReadAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Status != StateRegistered {
+		t.Errorf("Expected the asset to be registered, got %s", asset.Status)
+	}
+}
+
+func TestLifecycleRejectsIllegalJumps(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+
+	err := s.ApproveRequestOne(ctx, "asset1")
+	if err == nil {
+		t.Error("A draft was approved without being submitted")
+	}
+	err = s.ApproveRequestTwo(ctx, "asset1")
+	if err == nil {
+		t.Error("A draft received the second approval")
+	}
+	err = s.UpdateAsset(ctx, "asset1", "first", "Org1MSP", 1, 1, 1)
+	if err == nil {
+		t.Error("An update moved a draft straight to registered")
+	}
+	err = s.CreateAsset(ctx, "asset2", "second", "Org1MSP", 1, 1, 1)
+	if err == nil {
+		t.Error("An asset was created registered")
+	}
+}
+
+func TestRejectRequestRequiresOwner(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	stranger := newTestContext(stub, "Org3MSP", member)
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+
+	err := s.RejectRequest(stranger, "asset1")
+	if err == nil {
+		t.Error("A stranger rejected the request")
+	}
+	err = s.SubmitForApproval(newTestContext(stub, "Org2MSP", member), "asset1")
+	if err == nil {
+		t.Error("A stranger submitted the asset")
+	}
+}
+
+func TestRejectRequestDropsAmendment(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+	for _, step := range []func(contractapi.TransactionContextInterface, string) error{s.SubmitForApproval, s.ApproveRequestOne, s.ApproveRequestTwo} {
+		err := step(ctx, "asset1")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := s.AmendAsset(ctx, "asset1", "amended")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.ApproveRequestOne(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAllowedTransitions(t, s, ctx, "approved_two,registered")
+
+	err = s.RejectRequest(newTestContext(stub, "Org3MSP", member), "asset1")
+	if err == nil {
+		t.Error("A stranger rejected the amendment")
+	}
+	err = s.RejectRequest(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	asset, err := s.ReadAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Status != StateRegistered || asset.Description != "first" || asset.PendingAmendment != nil {
+		t.Errorf("The rejected amendment was not dropped: %+v", asset)
+	}
+}
+
+func checkAllowedTransitions(t *testing.T, s *SmartContract, ctx contractapi.TransactionContextInterface, want string) {
+	t.Helper()
+
+	transitions, err := s.GetAllowedTransitions(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(transitions, ","); got != want {
+		t.Errorf("Expected the transitions %s, got %s", want, got)
+	}
+}
+
+func TestQueryAssetsByMetadata(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+	mustCreateAsset(t, ctx, "asset2", "second", "Org1MSP")
+	for id, region := range map[string]string{"asset1": "eu", "asset2": "us"} {
+		err := s.SetMetadata(ctx, id, "region", region)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := s.QueryAssetsByMetadata(ctx, "region", "eu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Key != "asset1" {
+		t.Errorf("Expected only asset1 in the eu region, got %+v", results)
+	}
+
+	results, err = s.QueryAssetsByMetadata(ctx, "region", "asia")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no asset in the asia region, got %+v", results)
+	}
+
+	_, err = s.QueryAssetsByMetadata(ctx, `region"}`, "eu")
+	if err == nil {
+		t.Error("A key that breaks out of the selector was accepted")
+	}
+}
+
+func TestSetMetadataRequiresOwner(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	owner := newTestContext(stub, "Org1MSP", member)
+	mustCreateAsset(t, owner, "asset1", "first", "Org1MSP")
+
+	err := s.SetMetadata(newTestContext(stub, "Org3MSP", member), "asset1", "region", "eu")
+	if err == nil {
+		t.Error("A stranger set the metadata of the asset")
+	}
+	err = s.SetMetadata(owner, "asset1", "region", "eu")
+	if err != nil {
+		t.Errorf("The owner could not set the metadata of the asset: %s", err)
+	}
+}
+
+func TestTransferAssetEventCarriesBothOwners(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	stub.begin("transfer")
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+
+	err := s.TransferAsset(ctx, "asset1", "Org2MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var event TransferEvent
+	err = json.Unmarshal(stub.events["AssetTransferred"], &event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.ID != "asset1" || event.PreviousOwner != "Org1MSP" || event.NewOwner != "Org2MSP" {
+		t.Errorf("Expected the transfer from Org1MSP to Org2MSP, got %+v", event)
+	}
+	if event.TxID != "transfer" || event.Timestamp.Unix() != stub.now {
+		t.Errorf("Expected the transaction ID and time, got %+v", event)
+	}
+}
+
+func TestGetOwnerlessAssets(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+	mustCreateAsset(t, ctx, "asset2", "second", " \t")
+	mustCreateAsset(t, ctx, "asset3", "third", "Org2MSP")
+
+	results, err := s.GetOwnerlessAssets(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Key != "asset2" {
+		t.Errorf("Expected only the blank owned asset2, got %+v", results)
+	}
+}
+
+func TestRequiredApprovers(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+	err := s.SubmitForApproval(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SetRequiredApprovers(ctx, "asset1", []string{"Org1MSP", "Org2MSP", "Org3MSP"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.ApproveRequestOne(newTestContext(stub, "Org9MSP", admin), "asset1")
+	if err == nil {
+		t.Error("An approver off the required list approved the asset")
+	}
+
+	err = s.ApproveRequestOne(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.ApproveRequestTwo(newTestContext(stub, "Org2MSP", admin), "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	asset, err := s.ReadAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Registered != 0 || len(asset.SignedApprovers) != 2 {
+		t.Errorf("Expected 2 signatures and no registration yet, got %+v", asset)
+	}
+
+	err = s.ApproveRequestTwo(newTestContext(stub, "Org3MSP", admin), "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	asset, err = s.ReadAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Registered != 1 || asset.Status != StateRegistered {
+		t.Errorf("Expected the asset to be registered once every approver signed, got %+v", asset)
+	}
+}
+
+func TestGetAssetByExternalRef(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+	mustCreateAsset(t, ctx, "asset2", "second", "Org1MSP")
+
+	err := s.SetExternalRef(ctx, "asset1", "ERP-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset, err := s.GetAssetByExternalRef(ctx, "ERP-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.ID != "asset1" {
+		t.Errorf("Expected asset1, got %s", asset.ID)
+	}
+
+	err = s.SetExternalRef(ctx, "asset2", "ERP-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.GetAssetByExternalRef(ctx, "ERP-1")
+	if err == nil || !strings.Contains(err.Error(), "asset1") || !strings.Contains(err.Error(), "asset2") {
+		t.Errorf("Expected an error naming both assets, got %v", err)
+	}
+
+	err = s.SetExternalRef(ctx, "asset2", "ERP-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset, err = s.GetAssetByExternalRef(ctx, "ERP-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.ID != "asset1" {
+		t.Errorf("The old reference still points at %s", asset.ID)
+	}
+}
+
+func TestSetExternalRefRequiresOwner(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	owner := newTestContext(stub, "Org1MSP", member)
+	mustCreateAsset(t, owner, "asset1", "first", "Org1MSP")
+
+	err := s.SetExternalRef(newTestContext(stub, "Org3MSP", member), "asset1", "ERP-1")
+	if err == nil {
+		t.Error("A stranger set the external reference of the asset")
+	}
+	err = s.SetExternalRef(owner, "asset1", "ERP-1")
+	if err != nil {
+		t.Errorf("The owner could not set the external reference of the asset: %s", err)
+	}
+}
+
+func TestDeleteAssetRemovesIndexEntries(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+	err := s.SetExternalRef(ctx, "asset1", "ERP-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.DeleteAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, index := range secondaryIndexes {
+		resultsIterator, err := stub.GetStateByPartialCompositeKey(index, []string{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resultsIterator.HasNext() {
+			t.Errorf("The %s index still holds an entry", index)
+		}
+		resultsIterator.Close()
+	}
+}
+
+func TestGetAssetFeedResumes(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	for _, id := range []string{"asset1", "asset2", "asset3", "asset4"} {
+		err := s.RegisterAssetDirectly(ctx, id, "registered", "Org1MSP")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustCreateAsset(t, ctx, "asset5", "draft", "Org1MSP")
+
+	first, err := s.GetAssetFeed(ctx, "", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Bookmark == "" {
+		t.Fatal("The first page returned no bookmark")
+	}
+	second, err := s.GetAssetFeed(ctx, first.Bookmark, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []string{}
+	for _, result := range append(first.Records, second.Records...) {
+		ids = append(ids, result.Key)
+	}
+	if got := strings.Join(ids, ","); got != "asset1,asset2,asset3,asset4" {
+		t.Errorf("Expected every registered asset once, got %s", got)
+	}
+}
+
+func TestOwnerMSPAllowlist(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+
+	err := s.SetOwnerMSPAllowlist(newTestContext(stub, "Org2MSP", admin), []string{"Org2MSP"})
+	if err == nil {
+		t.Error("A client outside the admin MSP set the allowlist")
+	}
+	err = s.SetOwnerMSPAllowlist(ctx, []string{"Org1MSP", "Org2MSP"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.CreateAsset(ctx, "asset1", "first", "Org2MSP", 0, 0, 0)
+	if err != nil {
+		t.Errorf("An allowed owner was rejected: %s", err)
+	}
+	err = s.CreateAsset(ctx, "asset2", "second", "Org9MSP", 0, 0, 0)
+	if err == nil {
+		t.Error("An owner off the allowlist was accepted")
+	}
+}
+
+func TestGetApprovalLog(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+	err := s.SubmitForApproval(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stub.begin("approval1")
+	err = s.ApproveRequestOne(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub.begin("approval2")
+	err = s.ApproveRequestTwo(newTestContext(stub, "Org2MSP", admin), "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log, err := s.GetApprovalLog(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != 2 {
+		t.Fatalf("Expected 2 approval records, got %+v", log)
+	}
+	if log[0].ApproverMSP != "Org1MSP" || log[0].TxID != "approval1" || log[1].ApproverMSP != "Org2MSP" || log[1].TxID != "approval2" {
+		t.Errorf("Unexpected approval records %+v", log)
+	}
+}
+
+func TestCreateAssetAutoIDWithTxHashStrategy(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	stub.begin("abc123")
+
+	id, err := s.CreateAssetAutoID(ctx, "first", "Org1MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "asset-abc123" {
+		t.Errorf("Expected the ID derived from the transaction, got %s", id)
+	}
+}
+
+func TestCreateAssetAutoIDWithSequentialStrategy(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+
+	err := s.SetIDStrategy(ctx, "uuid")
+	if err == nil {
+		t.Error("An unknown strategy was accepted")
+	}
+	err = s.SetIDStrategy(ctx, "sequential")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"asset-1", "asset-2"} {
+		stub.begin("create-" + want)
+		id, err := s.CreateAssetAutoID(ctx, "first", "Org1MSP")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != want {
+			t.Errorf("Expected %s, got %s", want, id)
+		}
+	}
+}
+
+func TestCreateAssetAutoIDSkipsTakenSequentialIDs(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	err := s.SetIDStrategy(ctx, "sequential")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustCreateAsset(t, ctx, "asset-1", "manual", "Org1MSP")
+	mustCreateAsset(t, ctx, "asset-2", "manual", "Org1MSP")
+
+	for _, want := range []string{"asset-3", "asset-4"} {
+		stub.begin("create-" + want)
+		id, err := s.CreateAssetAutoID(ctx, "generated", "Org1MSP")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != want {
+			t.Errorf("Expected %s, got %s", want, id)
+		}
+	}
+}
+
+func TestQueryAssetsOwnedByAll(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "joint", "Org1MSP")
+	mustCreateAsset(t, ctx, "asset2", "sole", "Org1MSP")
+	err := s.AddOwner(ctx, "asset1", "Org2MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := s.QueryAssetsOwnedByAll(ctx, `["Org1MSP","Org2MSP"]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Key != "asset1" {
+		t.Errorf("Expected only the jointly owned asset1, got %+v", results)
+	}
+
+	results, err = s.QueryAssetsOwnedByAll(ctx, `["Org1MSP","Org3MSP"]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no asset owned by Org1MSP and Org3MSP, got %+v", results)
+	}
+
+	for _, ownersJSON := range []string{`[]`, `[""]`, `"Org1MSP"`} {
+		_, err = s.QueryAssetsOwnedByAll(ctx, ownersJSON)
+		if err == nil {
+			t.Errorf("The owners %s were accepted", ownersJSON)
+		}
+	}
+}
+
+func TestAddAndRemoveCoOwners(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "joint", "Org1MSP")
+
+	for _, owner := range []string{"Org2MSP", "Org3MSP"} {
+		err := s.AddOwner(ctx, "asset1", owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkOwners(t, s, ctx, "Org1MSP", "Org1MSP,Org2MSP,Org3MSP")
+
+	err := s.TransferAsset(ctx, "asset1", "Org4MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkOwners(t, s, ctx, "Org4MSP", "Org4MSP,Org2MSP,Org3MSP")
+
+	err = s.RemoveOwner(ctx, "asset1", "Org4MSP")
+	if err == nil {
+		t.Error("The primary owner was removed")
+	}
+	err = s.RemoveOwner(ctx, "asset1", "Org2MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkOwners(t, s, ctx, "Org4MSP", "Org4MSP,Org3MSP")
+
+	err = s.TransferSoleOwnership(ctx, "asset1", "Org5MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkOwners(t, s, ctx, "Org5MSP", "Org5MSP")
+}
+
+func TestReadAssetMigratesSingleOwnerRecords(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	err := stub.MockStub.PutState("asset1", []byte(`{"ID":"asset1","owner":"Org1MSP"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkOwners(t, s, ctx, "Org1MSP", "Org1MSP")
+}
+
+func TestTransferSoleOwnershipRequiresOwner(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	owner := newTestContext(stub, "Org1MSP", member)
+	mustCreateAsset(t, owner, "asset1", "joint", "Org1MSP")
+	err := s.AddOwner(owner, "asset1", "Org2MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.TransferSoleOwnership(newTestContext(stub, "Org3MSP", member), "asset1", "Org3MSP")
+	if err == nil {
+		t.Error("A stranger took sole ownership of the asset")
+	}
+
+	err = s.TransferSoleOwnership(newTestContext(stub, "Org2MSP", member), "asset1", "Org2MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkOwners(t, s, owner, "Org2MSP", "Org2MSP")
+
+	var event TransferEvent
+	err = json.Unmarshal(stub.events["AssetTransferred"], &event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.PreviousOwner != "Org1MSP" || event.NewOwner != "Org2MSP" {
+		t.Errorf("Expected the transfer from Org1MSP to Org2MSP, got %+v", event)
+	}
+}
+
+func checkOwners(t *testing.T, s *SmartContract, ctx contractapi.TransactionContextInterface, owner, owners string) {
+	t.Helper()
+
+	asset, err := s.ReadAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Owner != owner || strings.Join(asset.Owners, ",") != owners {
+		t.Errorf("Expected owner %s and owners %s, got %s and %s", owner, owners, asset.Owner, strings.Join(asset.Owners, ","))
+	}
+}
+
+func TestGetAssetTimelineMergesHistoryAndApprovals(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+
+	stub.begin("tx1")
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+	err := s.SubmitForApproval(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub.now = 2000
+	stub.begin("tx2")
+	err = s.ApproveRequestOne(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub.now = 3000
+	stub.begin("tx3")
+	err = s.UpdateAsset(ctx, "asset1", "changed", "Org1MSP", 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timeline, err := s.GetAssetTimeline(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := []string{}
+	for _, event := range timeline {
+		events = append(events, event.Type+"@"+event.TxID)
+	}
+	want := "created@tx1,updated@tx1,updated@tx2,approvalOne@tx2,updated@tx3"
+	if got := strings.Join(events, ","); got != want {
+		t.Errorf("Expected the timeline %s, got %s", want, got)
+	}
+}
+
+func TestReadAssetLightMatchesReadAsset(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+	for _, step := range []func(contractapi.TransactionContextInterface, string) error{s.SubmitForApproval, s.ApproveRequestOne} {
+		err := step(ctx, "asset1")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	asset, err := s.ReadAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary, err := s.ReadAssetLight(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.ID != asset.ID || summary.Owner != asset.Owner || summary.Status != asset.Status {
+		t.Errorf("The summary %+v does not match the asset %+v", summary, asset)
+	}
+	if summary.Status != StateApprovedOne {
+		t.Errorf("Expected the approved_one status, got %s", summary.Status)
+	}
+}
+
+func TestUpdateAssetRejectsRegisteredAsset(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	err := s.RegisterAssetDirectly(ctx, "asset1", "first", "Org1MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.UpdateAsset(newTestContext(stub, "Org1MSP", member), "asset1", "changed", "Org1MSP", 1, 1, 1)
+	if err == nil {
+		t.Error("A registered asset was updated without the admin attribute")
+	}
+
+	err = s.UpdateAsset(ctx, "asset1", "changed", "Org1MSP", 1, 1, 1)
+	if err != nil {
+		t.Errorf("The admin override was rejected: %s", err)
+	}
+}
+
+func TestRegisteredDescriptionChangesOnlyThroughAmendment(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	owner := newTestContext(stub, "Org1MSP", member)
+	mustCreateAsset(t, owner, "asset1", "first", "Org1MSP")
+	for _, step := range []func(contractapi.TransactionContextInterface, string) error{s.SubmitForApproval, s.ApproveRequestOne, s.ApproveRequestTwo} {
+		err := step(owner, "asset1")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	swapped, err := s.CompareAndSwapField(owner, "asset1", "description", "first", "changed")
+	if err == nil || swapped {
+		t.Error("The description of a registered asset was swapped without the admin attribute")
+	}
+	swapped, err = s.CompareAndSwapField(newTestContext(stub, "Org1MSP", admin), "asset1", "description", "first", "corrected")
+	if err != nil || !swapped {
+		t.Errorf("The admin override was rejected: %v", err)
+	}
+
+	err = s.AmendAsset(owner, "asset1", "amended")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range []func(contractapi.TransactionContextInterface, string) error{s.ApproveRequestOne, s.ApproveRequestTwo} {
+		err := step(owner, "asset1")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	asset, err := s.ReadAsset(owner, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Description != "amended" {
+		t.Errorf("The approved amendment was not applied, got %s", asset.Description)
+	}
+	err = s.TransferAsset(owner, "asset1", "Org2MSP")
+	if err != nil {
+		t.Errorf("A transfer of the registered asset was rejected: %s", err)
+	}
+}
+
+func TestQueryAssetsByMinApprovals(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "draft", "Org1MSP")
+	mustCreateAsset(t, ctx, "asset2", "approved once", "Org1MSP")
+	for _, step := range []func(contractapi.TransactionContextInterface, string) error{s.SubmitForApproval, s.ApproveRequestOne} {
+		err := step(ctx, "asset2")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := s.RegisterAssetDirectly(ctx, "asset3", "registered", "Org1MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for threshold, want := range map[int]string{1: "asset2,asset3", 2: "asset3"} {
+		results, err := s.QueryAssetsByMinApprovals(ctx, threshold)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []string{}
+		for _, result := range results {
+			ids = append(ids, result.Key)
+		}
+		if got := strings.Join(ids, ","); got != want {
+			t.Errorf("Expected %s with at least %d approvals, got %s", want, threshold, got)
+		}
+	}
+
+	_, err = s.QueryAssetsByMinApprovals(ctx, -1)
+	if err == nil {
+		t.Error("A negative threshold was accepted")
+	}
+}
+
+func TestSubmitForApproval(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	owner := newTestContext(stub, "Org1MSP", member)
+	mustCreateAsset(t, owner, "asset1", "first", "Org1MSP")
+
+	err := s.ApproveRequestOne(owner, "asset1")
+	if err == nil {
+		t.Error("A draft was approved before it was submitted")
+	}
+	err = s.SubmitForApproval(newTestContext(stub, "Org2MSP", member), "asset1")
+	if err == nil {
+		t.Error("A client other than the owner submitted the asset")
+	}
+
+	err = s.SubmitForApproval(owner, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset, err := s.ReadAsset(owner, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Status != StateSubmitted {
+		t.Errorf("Expected the asset to be submitted, got %s", asset.Status)
+	}
+	err = s.ApproveRequestOne(owner, "asset1")
+	if err != nil {
+		t.Errorf("The submitted asset could not be approved: %s", err)
+	}
+}
+
+func TestGetCreationHistogram(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	stub.now = time.Date(2023, time.November, 14, 22, 0, 0, 0, time.UTC).Unix()
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+	mustCreateAsset(t, ctx, "asset2", "second", "Org1MSP")
+	stub.now += 24 * 60 * 60
+	mustCreateAsset(t, ctx, "asset3", "third", "Org1MSP")
+
+	days, err := s.GetCreationHistogram(ctx, "day")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 2 || days["2023-11-14"] != 2 || days["2023-11-15"] != 1 {
+		t.Errorf("Unexpected daily buckets %v", days)
+	}
+
+	months, err := s.GetCreationHistogram(ctx, "month")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(months) != 1 || months["2023-11"] != 3 {
+		t.Errorf("Unexpected monthly buckets %v", months)
+	}
+
+	_, err = s.GetCreationHistogram(ctx, "week")
+	if err == nil {
+		t.Error("An unknown granularity was accepted")
+	}
+}
+
+func TestSampleAssetsIsDeterministic(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	for i := 0; i < 10; i++ {
+		mustCreateAsset(t, ctx, fmt.Sprintf("asset%d", i), "sampled", "Org1MSP")
+	}
+
+	first, err := s.SampleAssets(ctx, 3, "seed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.SampleAssets(ctx, 3, "seed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 3 || len(second) != 3 {
+		t.Fatalf("Expected samples of 3, got %d and %d", len(first), len(second))
+	}
+	for i := range first {
+		if first[i].Key != second[i].Key {
+			t.Errorf("The samples differ at %d: %s and %s", i, first[i].Key, second[i].Key)
+		}
+	}
+
+	all, err := s.SampleAssets(ctx, 30, "seed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 10 {
+		t.Errorf("Expected every asset when sampling more than there are, got %d", len(all))
+	}
+}
+
+func TestExportAssetGraph(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	for _, id := range []string{"asset1", "asset2", "asset3"} {
+		mustCreateAsset(t, ctx, id, "linked", "Org1MSP")
+	}
+	err := s.SetReferences(ctx, "asset1", []string{"asset2", "asset3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SetReferences(ctx, "asset2", []string{"asset9"})
+	if err == nil {
+		t.Error("A reference to a missing asset was accepted")
+	}
+
+	graph, err := s.ExportAssetGraph(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"nodes":["asset1","asset2","asset3"],"edges":[{"from":"asset1","to":"asset2"},{"from":"asset1","to":"asset3"}],"truncated":false}`
+	if graph != want {
+		t.Errorf("Expected the graph %s, got %s", want, graph)
+	}
+}
+
+func TestSetReferencesRequiresOwner(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	owner := newTestContext(stub, "Org1MSP", member)
+	mustCreateAsset(t, owner, "asset1", "linked", "Org1MSP")
+	mustCreateAsset(t, owner, "asset2", "linked", "Org1MSP")
+
+	err := s.SetReferences(newTestContext(stub, "Org3MSP", member), "asset1", []string{"asset2"})
+	if err == nil {
+		t.Error("A stranger set the references of the asset")
+	}
+	err = s.SetReferences(owner, "asset1", []string{"asset2"})
+	if err != nil {
+		t.Errorf("The owner could not set the references of the asset: %s", err)
+	}
+}
+
+func TestAmendAsset(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "original", "Org1MSP")
+
+	err := s.AmendAsset(ctx, "asset1", "amended")
+	if err == nil {
+		t.Error("An unregistered asset was amended")
+	}
+	for _, step := range []func(contractapi.TransactionContextInterface, string) error{s.SubmitForApproval, s.ApproveRequestOne, s.ApproveRequestTwo} {
+		err := step(ctx, "asset1")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = s.AmendAsset(ctx, "asset1", "amended")
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset, err := s.ReadAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Status != StatePendingAmendment || asset.Description != "original" || asset.PendingAmendment == nil || asset.PendingAmendment.Description != "amended" {
+		t.Fatalf("The amendment was not proposed: %+v", asset)
+	}
+
+	for _, step := range []func(contractapi.TransactionContextInterface, string) error{s.ApproveRequestOne, s.ApproveRequestTwo} {
+		err := step(ctx, "asset1")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	asset, err = s.ReadAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Status != StateRegistered || asset.Description != "amended" || asset.PendingAmendment != nil {
+		t.Errorf("The approved amendment was not applied: %+v", asset)
+	}
+}
+
+func TestGetAssetsByIDPrefix(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	for _, id := range []string{"invoice-1", "invoice-2", "invoices", "order-1"} {
+		mustCreateAsset(t, ctx, id, "prefixed", "Org1MSP")
+	}
+
+	results, err := s.GetAssetsByIDPrefix(ctx, "invoice-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Key != "invoice-1" || results[1].Key != "invoice-2" {
+		t.Errorf("Expected invoice-1 and invoice-2, got %+v", results)
+	}
+
+	results, err = s.GetAssetsByIDPrefix(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4 {
+		t.Errorf("Expected every asset for an empty prefix, got %d", len(results))
+	}
+
+	_, err = s.GetAssetsByIDPrefix(ctx, internalKeyPrefix+"config")
+	if err == nil {
+		t.Error("A reserved prefix was accepted")
+	}
+}
+
+func TestCountAssetsTracksCreatesAndDeletes(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "counted", "Org1MSP")
+	mustCreateAsset(t, ctx, "asset2", "counted", "Org1MSP")
+	mustCreateAsset(t, ctx, "asset3", "counted", "Org1MSP")
+	err := s.DeleteAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count, found, err := getCounter(ctx, totalAssetsCounter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found || count != 2 {
+		t.Errorf("Expected the counter to hold 2, got %d", count)
+	}
+
+	err = stub.DelState(counterKeyPrefix + totalAssetsCounter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err = s.CountAssets(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("Expected the scan to count 2 assets, got %d", count)
+	}
+}
+
+func TestGetLatestAssetForOwner(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "older", "Org1MSP")
+	stub.now += 3600
+	mustCreateAsset(t, ctx, "asset2", "newer", "Org1MSP")
+	stub.now += 3600
+	mustCreateAsset(t, ctx, "asset3", "other owner", "Org2MSP")
+
+	asset, err := s.GetLatestAssetForOwner(ctx, "Org1MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.ID != "asset2" {
+		t.Errorf("Expected the newer asset2, got %s", asset.ID)
+	}
+
+	_, err = s.GetLatestAssetForOwner(ctx, "Org3MSP")
+	if err == nil {
+		t.Error("An owner without assets did not get a not-found error")
+	}
+}
+
+func TestSellAsset(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	stub.begin("sale")
+	mustCreateAsset(t, ctx, "asset1", "for sale", "Org1MSP")
+
+	err := s.SellAsset(ctx, "asset1", "Org2MSP", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset, err := s.ReadAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Owner != "Org2MSP" || len(asset.SaleLog) != 1 {
+		t.Fatalf("The sale was not recorded: %+v", asset)
+	}
+	sale := asset.SaleLog[0]
+	if sale.From != "Org1MSP" || sale.To != "Org2MSP" || sale.Price != 10 || sale.TxID != "sale" || sale.Timestamp.Unix() != stub.now {
+		t.Errorf("Unexpected sale record %+v", sale)
+	}
+
+	err = s.SellAsset(ctx, "asset1", "Org3MSP", -1)
+	if err == nil {
+		t.Error("A negative price was accepted")
+	}
+}
+
+func TestGetInconsistentAssets(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "consistent", "Org1MSP")
+	mustCreateAsset(t, ctx, "asset2", "inconsistent", "Org1MSP")
+
+	asset, err := s.ReadAsset(ctx, "asset2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset.Registered = 1
+	assetJSON, err := json.Marshal(asset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = stub.PutState("asset2", assetJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := s.GetInconsistentAssets(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Key != "asset2" {
+		t.Errorf("Expected only asset2, got %+v", results)
+	}
+}
+
+func TestGetStalePendingAssets(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "stale", "Org1MSP")
+	err := s.RegisterAssetDirectly(ctx, "asset2", "stale but registered", "Org1MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub.now += 3 * 3600
+	mustCreateAsset(t, ctx, "asset3", "recent", "Org1MSP")
+	stub.now += 3600
+
+	results, err := s.GetStalePendingAssets(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Key != "asset1" {
+		t.Errorf("Expected only asset1, got %+v", results)
+	}
+
+	_, err = s.GetStalePendingAssets(ctx, 0)
+	if err == nil {
+		t.Error("A non-positive age was accepted")
+	}
+}
+
+func TestCreateContentAddressedAsset(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+
+	id, err := s.CreateContentAddressedAsset(ctx, "My  Car", "Org1MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := contentAddress(" my car ", "Org1MSP"); id != want {
+		t.Errorf("Expected the normalized content to give %s, got %s", want, id)
+	}
+
+	_, err = s.CreateContentAddressedAsset(ctx, "my car", "")
+	if err == nil {
+		t.Error("The same content was created twice")
+	}
+
+	other, err := s.CreateContentAddressedAsset(ctx, "my car", "Org2MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == id {
+		t.Error("Different content gave the same ID")
+	}
+}
+
+func TestExportAssetsProjectsFields(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "exported", "Org1MSP")
+
+	rows, err := s.ExportAssets(ctx, `["ID","owner","approvalOne","externalRef"]`, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"ID": "asset1", "owner": "Org1MSP", "approvalOne": "0", "externalRef": ""}
+	if len(rows) != 1 || len(rows[0]) != len(want) {
+		t.Fatalf("Expected one row of %d fields, got %v", len(want), rows)
+	}
+	for field, value := range want {
+		got, ok := rows[0][field]
+		if !ok || got != value {
+			t.Errorf("Expected %s to be %q, got %q", field, value, got)
+		}
+	}
+
+	_, err = s.ExportAssets(ctx, `["bogus"]`, false)
+	if err == nil {
+		t.Error("An unknown field was accepted")
+	}
+}
+
+func TestEscrowAndRelease(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	agent := newTestContext(stub, "EscrowMSP", member)
+	mustCreateAsset(t, ctx, "asset1", "held", "Org1MSP")
+
+	err := s.EscrowAsset(ctx, "asset1", "EscrowMSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset, err := s.ReadAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !asset.InEscrow || asset.Owner != "EscrowMSP" {
+		t.Errorf("The asset is not held by the escrow agent: %+v", asset)
+	}
+
+	err = s.TransferAsset(ctx, "asset1", "Org2MSP")
+	if err == nil {
+		t.Error("An asset in escrow was transferred")
+	}
+	err = s.AddOwner(ctx, "asset1", "Org2MSP")
+	if err == nil {
+		t.Error("A co-owner was added to an asset in escrow")
+	}
+	err = s.ReleaseFromEscrow(ctx, "asset1", "Org2MSP")
+	if err == nil {
+		t.Error("A client other than the escrow agent released the asset")
+	}
+
+	err = s.ReleaseFromEscrow(agent, "asset1", "Org2MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset, err = s.ReadAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.InEscrow || asset.Owner != "Org2MSP" || strings.Join(asset.Owners, ",") != "Org2MSP" {
+		t.Errorf("The asset was not released to Org2MSP: %+v", asset)
+	}
+}
+
+func TestGetRequiredApprovers(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "counted", "Org1MSP")
+	mustCreateAsset(t, ctx, "asset2", "named", "Org1MSP")
+	err := s.SetRequiredApprovers(ctx, "asset2", []string{"Org1MSP", "Org2MSP"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"asset1", "asset2"} {
+		for _, step := range []func(contractapi.TransactionContextInterface, string) error{s.SubmitForApproval, s.ApproveRequestOne} {
+			err := step(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		approvers, err := s.GetRequiredApprovers(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(approvers) != 2 || approvers[0].Approver != "Org1MSP" || !approvers[0].Signed || approvers[1].Signed {
+			t.Errorf("Expected one granted and one pending approval on %s, got %+v", id, approvers)
+		}
+	}
+}
+
+func TestGetOwnersAboveCount(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "counted", "Org1MSP")
+	mustCreateAsset(t, ctx, "asset2", "counted", "Org1MSP")
+	mustCreateAsset(t, ctx, "asset3", "counted", "Org2MSP")
+
+	owners, err := s.GetOwnersAboveCount(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(owners) != 1 || owners["Org1MSP"] != 2 {
+		t.Errorf("Expected only Org1MSP with 2 assets, got %v", owners)
+	}
+
+	_, err = s.GetOwnersAboveCount(ctx, -1)
+	if err == nil {
+		t.Error("A negative threshold was accepted")
+	}
+}
+
+func TestResetApprovals(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	err := s.RegisterAssetDirectly(ctx, "asset1", "registered", "Org1MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.ResetApprovals(newTestContext(stub, "Org2MSP", admin), "asset1")
+	if err == nil {
+		t.Error("A client outside the admin MSP reset the approvals")
+	}
+
+	err = s.ResetApprovals(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset, err := s.ReadAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.ApprovalOne != 0 || asset.ApprovalTwo != 0 || asset.Registered != 0 || asset.Status != StateDraft {
+		t.Errorf("The approvals were not reset: %+v", asset)
+	}
+	if len(asset.ApprovalLog) != 1 || asset.ApprovalLog[0].ApprovalType != "reset" {
+		t.Errorf("The reset was not logged: %+v", asset.ApprovalLog)
+	}
+}
+
+func TestGetAssetsRegisteredBetween(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	err := s.RegisterAssetDirectly(ctx, "asset1", "before", "Org1MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub.now += 24 * 60 * 60
+	err = s.RegisterAssetDirectly(ctx, "asset2", "inside", "Org1MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustCreateAsset(t, ctx, "asset3", "never registered", "Org1MSP")
+	stub.now += 24 * 60 * 60
+	err = s.RegisterAssetDirectly(ctx, "asset4", "after", "Org1MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inside, err := s.ReadAsset(ctx, "asset2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := inside.RegisteredAt.Add(-time.Hour).Format(time.RFC3339)
+	end := inside.RegisteredAt.Add(time.Hour).Format(time.RFC3339)
+
+	results, err := s.GetAssetsRegisteredBetween(ctx, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Key != "asset2" {
+		t.Errorf("Expected only asset2, got %+v", results)
+	}
+
+	_, err = s.GetAssetsRegisteredBetween(ctx, end, start)
+	if err == nil {
+		t.Error("A window ending before it starts was accepted")
+	}
+	_, err = s.GetAssetsRegisteredBetween(ctx, "yesterday", end)
+	if err == nil {
+		t.Error("A bound that is not RFC 3339 was accepted")
+	}
+}
+
+func TestGetOwnerDistribution(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+
+	distribution, err := s.GetOwnerDistribution(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(distribution) != 0 {
+		t.Errorf("Expected no shares on an empty ledger, got %v", distribution)
+	}
+
+	mustCreateAsset(t, ctx, "asset1", "counted", "Org1MSP")
+	mustCreateAsset(t, ctx, "asset2", "counted", "Org1MSP")
+	mustCreateAsset(t, ctx, "asset3", "counted", "Org1MSP")
+	mustCreateAsset(t, ctx, "asset4", "counted", "Org2MSP")
+
+	distribution, err = s.GetOwnerDistribution(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if distribution["Org1MSP"] != 75 || distribution["Org2MSP"] != 25 {
+		t.Errorf("Expected shares of 75 and 25, got %v", distribution)
+	}
+	total := 0.0
+	for _, share := range distribution {
+		total += share
+	}
+	if total != 100 {
+		t.Errorf("Expected the shares to sum to 100, got %v", total)
+	}
+}
+
+func TestCreateAssetRichSetsTagsAndMetadata(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "referenced", "Org1MSP")
+
+	err := s.CreateAssetRich(ctx, `{"ID":"asset2","description":"rich","tags":["red","blue"],"metadata":{"region":"eu"},"references":["asset1"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset, err := s.ReadAsset(ctx, "asset2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(asset.Tags, ",") != "red,blue" || asset.Metadata["region"] != "eu" || strings.Join(asset.References, ",") != "asset1" {
+		t.Errorf("The tags, metadata or references were not set: %+v", asset)
+	}
+	if asset.Owner != "Org1MSP" || asset.Status != StateDraft || asset.CreatedByMSP != "Org1MSP" {
+		t.Errorf("The asset was not created like CreateAsset would: %+v", asset)
+	}
+}
+
+func TestCreateAssetRichValidatesFields(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "existing", "Org1MSP")
+
+	for _, assetJSON := range []string{
+		`{"ID":"asset1","description":"duplicate"}`,
+		`{"ID":"asset2","status":"registered"}`,
+		`{"ID":"asset2","tags":["red","red"]}`,
+		`{"ID":"asset2","metadata":{"bad key":"eu"}}`,
+		`{"ID":"asset2","references":["missing"]}`,
+		`{"ID":"asset2","registered":1}`,
+	} {
+		err := s.CreateAssetRich(ctx, assetJSON)
+		if err == nil {
+			t.Errorf("The asset %s was accepted", assetJSON)
+		}
+	}
+}
+
+func TestGetAssetsMissingField(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "complete", "Org1MSP")
+	mustCreateAsset(t, ctx, "asset2", "complete", "Org1MSP")
+	err := stub.PutState("legacy", []byte(`{"ID":"legacy","description":"old","owner":"Org1MSP","approvalOne":0,"approvalTwo":0,"registered":0}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SetMetadata(ctx, "asset1", "region", "eu")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := s.GetAssetsMissingField(ctx, "createdAt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Key != "legacy" {
+		t.Errorf("Expected only the legacy record to miss createdAt, got %+v", results)
+	}
+
+	results, err = s.GetAssetsMissingField(ctx, "metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Errorf("Expected 2 records without metadata, got %+v", results)
+	}
+
+	_, err = s.GetAssetsMissingField(ctx, "bogus")
+	if err == nil {
+		t.Error("An unknown field was accepted")
+	}
+}
+
+func TestMigrateAssetsBackfillsFields(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+
+	created := stub.now
+	err := stub.PutState("legacy", []byte(`{"ID":"legacy","description":"old","owner":"Org1MSP","approvalOne":1,"approvalTwo":1,"registered":0}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub.now += 3600
+	stub.begin("register")
+	registered := stub.now
+	err = stub.PutState("legacy", []byte(`{"ID":"legacy","description":"old","owner":"Org1MSP","approvalOne":1,"approvalTwo":1,"registered":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustCreateAsset(t, ctx, "asset1", "current", "Org1MSP")
+	stub.now += 3600
+	stub.begin("migrate")
+
+	_, err = s.MigrateAssets(newTestContext(stub, "Org2MSP", admin))
+	if err == nil {
+		t.Error("A client outside the admin MSP migrated the assets")
+	}
+
+	migrated, err := s.MigrateAssets(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated != 1 {
+		t.Errorf("Expected only the legacy record to be migrated, got %d", migrated)
+	}
+	asset, err := s.ReadAsset(ctx, "legacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.CreatedAt.Unix() != created || asset.RegisteredAt.Unix() != registered || asset.Status != StateRegistered {
+		t.Errorf("The dates or status were not backfilled from history: %+v", asset)
+	}
+	if strings.Join(asset.Owners, ",") != "Org1MSP" || asset.Checksum == "" {
+		t.Errorf("The owners or checksum were not backfilled: %+v", asset)
+	}
+
+	stub.begin("migrate-again")
+	migrated, err = s.MigrateAssets(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated != 0 {
+		t.Errorf("A second migration rewrote %d assets", migrated)
+	}
+}
+
+func TestQueryAssetsPaginatedContinuesFromBookmark(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	for _, id := range []string{"asset1", "asset2", "asset3", "asset4", "asset5"} {
+		mustCreateAsset(t, ctx, id, "paged", "Org1MSP")
+	}
+	mustCreateAsset(t, ctx, "asset6", "paged", "Org2MSP")
+
+	ids := []string{}
+	bookmark := ""
+	for pages := 0; pages < 3; pages++ {
+		page, err := s.QueryAssetsPaginated(ctx, `{"owner":"Org1MSP"}`, 2, bookmark)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, result := range page.Records {
+			ids = append(ids, result.Key)
+		}
+		bookmark = page.Bookmark
+	}
+
+	if got := strings.Join(ids, ","); got != "asset1,asset2,asset3,asset4,asset5" {
+		t.Errorf("Expected the assets of Org1MSP once each, got %s", got)
+	}
+	if bookmark != "" {
+		t.Errorf("Expected no bookmark after the last page, got %s", bookmark)
+	}
+}
+
+func TestQueryAssetsPaginatedValidatesArguments(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+
+	_, err := s.QueryAssetsPaginated(ctx, `[1]`, 2, "")
+	if err == nil {
+		t.Error("A selector that is not an object was accepted")
+	}
+	_, err = s.QueryAssetsPaginated(ctx, `{}`, 0, "")
+	if err == nil {
+		t.Error("A zero page size was accepted")
+	}
+}
+
+func TestComments(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "reviewed", "Org1MSP")
+
+	comments, err := s.GetComments(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if comments == nil || len(comments) != 0 {
+		t.Errorf("Expected an empty list of comments, got %v", comments)
+	}
+
+	err = s.AddComment(newTestContext(stub, "Org2MSP", admin), "asset1", "looks fine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	comments, err = s.GetComments(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 1 || comments[0].AuthorMSP != "Org2MSP" || comments[0].Text != "looks fine" || comments[0].Timestamp.Unix() != stub.now {
+		t.Errorf("Unexpected comments %+v", comments)
+	}
+}
+
+func TestAddCommentEnforcesLimits(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "reviewed", "Org1MSP")
+
+	err := s.AddComment(ctx, "asset1", strings.Repeat("x", maxCommentLength+1))
+	if err == nil {
+		t.Error("A comment over the length limit was accepted")
+	}
+
+	for i := 0; i < maxComments; i++ {
+		err := s.AddComment(ctx, "asset1", fmt.Sprintf("comment %d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = s.AddComment(ctx, "asset1", "one too many")
+	if err == nil {
+		t.Error("A comment over the count limit was accepted")
+	}
+}
+
+func TestQueryAssetsAdvancedCombinesFilters(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	for id, owner := range map[string]string{"asset1": "OrgAMSP", "asset3": "OrgBMSP", "asset4": "OrgCMSP"} {
+		err := s.RegisterAssetDirectly(ctx, id, "registered", owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustCreateAsset(t, ctx, "asset2", "draft", "OrgBMSP")
+
+	results, err := s.QueryAssetsAdvanced(ctx, `{"and":[{"or":[{"field":"owner","eq":"OrgAMSP"},{"field":"owner","in":["OrgBMSP"]}]},{"field":"registered","eq":1}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Key != "asset1" || results[1].Key != "asset3" {
+		t.Errorf("Expected the registered assets of OrgAMSP and OrgBMSP, got %+v", results)
+	}
+}
+
+func TestQueryAssetsAdvancedRejectsBadFilters(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+
+	for _, filterJSON := range []string{
+		`{"not":[{"field":"owner","eq":"OrgAMSP"}]}`,
+		`{"field":"bogus","eq":1}`,
+		`{"field":"owner"}`,
+		`{"and":[]}`,
+		`{"and":[{"field":"owner","eq":"OrgAMSP"}],"field":"owner","eq":"OrgAMSP"}`,
+	} {
+		_, err := s.QueryAssetsAdvanced(ctx, filterJSON)
+		if err == nil {
+			t.Errorf("The filter %s was accepted", filterJSON)
+		}
+	}
+}
+
+func TestReplayEvents(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+
+	steps := []func(){
+		func() { mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP") },
+		func() { mustCreateAsset(t, ctx, "asset2", "second", "Org1MSP") },
+		func() {
+			if err := s.SetMetadata(ctx, "asset1", "region", "eu"); err != nil {
+				t.Fatal(err)
+			}
+		},
+		func() {
+			if err := s.DeleteAsset(ctx, "asset2"); err != nil {
+				t.Fatal(err)
+			}
+		},
+		func() { mustCreateAsset(t, ctx, "asset2", "recreated", "Org1MSP") },
+	}
+	for i, step := range steps {
+		stub.now += 10
+		stub.begin(fmt.Sprintf("tx%d", i))
+		step()
+	}
+
+	events, err := s.ReplayEvents(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := replayedEvents(events); got != "create:asset1,create:asset2,update:asset1,delete:asset2,create:asset2" {
+		t.Errorf("Unexpected replay %s", got)
+	}
+
+	events, err = s.ReplayEvents(ctx, "tx2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := replayedEvents(events); got != "delete:asset2,create:asset2" {
+		t.Errorf("Unexpected replay after tx2 %s", got)
+	}
+
+	_, err = s.ReplayEvents(ctx, "unknown")
+	if err == nil {
+		t.Error("An unknown transaction was accepted")
+	}
+}
+
+func replayedEvents(events []EventRecord) string {
+	replayed := []string{}
+	for _, event := range events {
+		replayed = append(replayed, event.Type+":"+event.ID)
+	}
+
+	return strings.Join(replayed, ",")
+}
+
+func TestAttachAndVerifyDocument(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "documented", "Org1MSP")
+	hash := strings.Repeat("AB", 32)
+
+	err := s.AttachDocument(ctx, "asset1", "deed", hash, "application/pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.AttachDocument(ctx, "asset1", "deed", hash, "application/pdf")
+	if err == nil {
+		t.Error("A document name was attached twice")
+	}
+	err = s.AttachDocument(ctx, "asset1", "survey", "abc", "")
+	if err == nil {
+		t.Error("A hash that is not SHA-256 was accepted")
+	}
+
+	matched, err := s.VerifyDocument(ctx, "asset1", "deed", strings.ToLower(hash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !matched {
+		t.Error("The attached hash did not verify")
+	}
+	matched, err = s.VerifyDocument(ctx, "asset1", "deed", strings.Repeat("cd", 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if matched {
+		t.Error("A different hash verified")
+	}
+
+	_, err = s.VerifyDocument(ctx, "asset1", "survey", hash)
+	if err == nil {
+		t.Error("A document that was never attached verified without error")
+	}
+}
+
+func TestGetTagUsage(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	for _, assetJSON := range []string{`{"ID":"asset1","tags":["red","blue"]}`, `{"ID":"asset2","tags":["blue"]}`} {
+		err := s.CreateAssetRich(ctx, assetJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustCreateAsset(t, ctx, "asset3", "untagged", "Org1MSP")
+
+	usage, err := s.GetTagUsage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage) != 2 || usage["red"] != 1 || usage["blue"] != 2 {
+		t.Errorf("Unexpected tag usage %v", usage)
+	}
+}
+
+func TestTransferAssetFailsWithEvent(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "transferred", "Org1MSP")
+
+	stub.failEvents = true
+	err := s.TransferAsset(ctx, "asset1", "Org2MSP")
+	if err == nil || !strings.Contains(err.Error(), "AssetTransferred") {
+		t.Errorf("Expected the event failure to fail the transfer, got %v", err)
+	}
+	err = s.CreateAsset(ctx, "asset2", "created", "Org1MSP", 0, 0, 0)
+	if err == nil {
+		t.Error("The event failure did not fail the creation")
+	}
+}
+
+func TestGetAssetCaseInsensitive(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "Asset-A", "mixed case", "Org1MSP")
+
+	asset, err := s.GetAssetCaseInsensitive(ctx, "asset-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.ID != "Asset-A" {
+		t.Errorf("Expected Asset-A, got %s", asset.ID)
+	}
+
+	_, err = s.GetAssetCaseInsensitive(ctx, "asset-b")
+	if err == nil {
+		t.Error("A missing asset was found")
+	}
+}
+
+func TestGetAssetCaseInsensitiveReportsCollisions(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "Asset-A", "mixed case", "Org1MSP")
+	mustCreateAsset(t, ctx, "ASSET-A", "upper case", "Org1MSP")
+
+	_, err := s.GetAssetCaseInsensitive(ctx, "asset-a")
+	if err == nil || !strings.Contains(err.Error(), "Asset-A") || !strings.Contains(err.Error(), "ASSET-A") {
+		t.Errorf("Expected an error naming both assets, got %v", err)
+	}
+
+	err = s.DeleteAsset(ctx, "ASSET-A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset, err := s.GetAssetCaseInsensitive(ctx, "asset-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.ID != "Asset-A" {
+		t.Errorf("Expected Asset-A once the collision is deleted, got %s", asset.ID)
+	}
+}
+
+// pageRecordingStub records the largest page of a paginated range query.
+type pageRecordingStub struct {
+	*testStub
+	largestPage int32
+}
+
+func (s *pageRecordingStub) GetStateByRangeWithPagination(startKey, endKey string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	resultsIterator, responseMetadata, err := s.testStub.GetStateByRangeWithPagination(startKey, endKey, pageSize, bookmark)
+	if err == nil && responseMetadata.FetchedRecordsCount > s.largestPage {
+		s.largestPage = responseMetadata.FetchedRecordsCount
+	}
+
+	return resultsIterator, responseMetadata, err
+}
+
+func TestProcessAllAssetsReadsInBatches(t *testing.T) {
+	s := new(SmartContract)
+	stub := &pageRecordingStub{testStub: newTestStub()}
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	for i := 0; i < 250; i++ {
+		mustCreateAsset(t, ctx, fmt.Sprintf("asset%03d", i), "bulk", "Org1MSP")
+	}
+	err := s.RegisterAssetDirectly(ctx, "registered", "bulk", "Org1MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := s.ProcessAllAssets(ctx, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.TotalAssets != 251 || summary.ByStatus["draft"] != 250 || summary.ByStatus["registered"] != 1 {
+		t.Errorf("Unexpected summary %+v", summary)
+	}
+	if summary.Batches != 7 {
+		t.Errorf("Expected 7 batches of at most 40 assets, got %d", summary.Batches)
+	}
+	if stub.largestPage > 40 {
+		t.Errorf("A page of %d records was read", stub.largestPage)
+	}
+
+	_, err = s.ProcessAllAssets(ctx, 0)
+	if err == nil {
+		t.Error("A zero batch size was accepted")
+	}
+}
+
+func TestQueryAssetsByAmountRange(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	for id, amount := range map[string]int{"below": 10, "boundary": 50, "inside": 30, "above": 51} {
+		mustCreateAsset(t, ctx, id, "valued", "Org1MSP")
+		err := s.SetAmount(ctx, id, amount)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := s.QueryAssetsByAmountRange(ctx, 20, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Key != "boundary" || results[1].Key != "inside" {
+		t.Errorf("Expected the assets inside and at the boundary, got %+v", results)
+	}
+
+	_, err = s.QueryAssetsByAmountRange(ctx, 5, 1)
+	if err == nil {
+		t.Error("A range with min above max was accepted")
+	}
+	err = s.SetAmount(ctx, "inside", -1)
+	if err == nil {
+		t.Error("A negative amount was accepted")
+	}
+}
+
+func TestExecuteBatchRunsMixedOperations(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "updated", "old", "Org1MSP")
+	mustCreateAsset(t, ctx, "deleted", "old", "Org1MSP")
+	mustCreateAsset(t, ctx, "transferred", "old", "Org1MSP")
+	stub.begin("batch")
+
+	result, err := s.ExecuteBatch(ctx, `[
+		{"op":"create","ID":"created","description":"new","owner":"Org1MSP"},
+		{"op":"update","ID":"updated","description":"new","owner":"Org1MSP"},
+		{"op":"delete","ID":"deleted"},
+		{"op":"transfer","ID":"transferred","newOwner":"Org2MSP"}
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Results) != 4 {
+		t.Errorf("Expected 4 results, got %+v", result.Results)
+	}
+
+	count, err := s.CountAssets(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 assets after the batch, got %d", count)
+	}
+	asset, err := s.ReadAsset(ctx, "transferred")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Owner != "Org2MSP" {
+		t.Errorf("The transfer was not applied, owner is %s", asset.Owner)
+	}
+	if stub.events["BatchExecuted"] == nil {
+		t.Error("No BatchExecuted event was emitted")
+	}
+}
+
+func TestExecuteBatchAbortsOnFailure(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "existing", "old", "Org1MSP")
+
+	_, err := s.ExecuteBatch(ctx, `[{"op":"create","ID":"created","owner":"Org1MSP"},{"op":"delete","ID":"missing"}]`)
+	if err == nil || !strings.Contains(err.Error(), "Operation 1") {
+		t.Errorf("Expected the second operation to abort the batch, got %v", err)
+	}
+
+	for _, opsJSON := range []string{
+		`[]`,
+		`[{"op":"rename","ID":"existing"}]`,
+		`[{"op":"delete","ID":"existing","owner":"Org1MSP"}]`,
+		`[{"op":"transfer","ID":"existing"}]`,
+		`[{"op":"delete","ID":"existing"},{"op":"transfer","ID":"existing","newOwner":"Org2MSP"}]`,
+	} {
+		_, err := s.ExecuteBatch(ctx, opsJSON)
+		if err == nil {
+			t.Errorf("The batch %s was accepted", opsJSON)
+		}
+	}
+}
+
+func TestGetUnmodifiedAssets(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "created", "untouched", "Org1MSP")
+	mustCreateAsset(t, ctx, "updated", "touched", "Org1MSP")
+	stub.now += 5
+	stub.begin("update")
+	err := s.SetAmount(ctx, "updated", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := s.GetUnmodifiedAssets(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Key != "created" {
+		t.Errorf("Expected only the created asset, got %+v", results)
+	}
+}
+
+func TestGetOwnershipDurations(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "held", "Org1MSP")
+	stub.now += 10
+	stub.begin("transfer1")
+	err := s.TransferAsset(ctx, "asset1", "Org2MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub.now += 20
+	stub.begin("transfer2")
+	err = s.TransferAsset(ctx, "asset1", "Org3MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub.now += 5
+	stub.begin("report")
+
+	durations, err := s.GetOwnershipDurations(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []OwnershipDuration{
+		{Owner: "Org1MSP", DurationSeconds: 10},
+		{Owner: "Org2MSP", DurationSeconds: 20},
+		{Owner: "Org3MSP", DurationSeconds: 5, Current: true},
+	}
+	if len(durations) != len(want) {
+		t.Fatalf("Expected %d ownerships, got %+v", len(want), durations)
+	}
+	for i, duration := range durations {
+		if duration.Owner != want[i].Owner || duration.DurationSeconds != want[i].DurationSeconds || duration.Current != want[i].Current {
+			t.Errorf("Expected ownership %+v, got %+v", want[i], duration)
+		}
+		if duration.End.Sub(duration.Start) != time.Duration(duration.DurationSeconds)*time.Second {
+			t.Errorf("The bounds of %+v do not match its duration", duration)
+		}
+	}
+}
+
+func TestUnknownTransaction(t *testing.T) {
+	contract := new(SmartContract)
+	contract.UnknownTransaction = contract.unknownTransaction
+	chaincode, err := contractapi.NewChaincode(contract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub := shimtest.NewMockStub("utility", chaincode)
+
+	response := stub.MockInvoke("tx1", [][]byte{[]byte("ReadAset"), []byte("asset1")})
+	if response.Status == shim.OK || response.Message != "Function ReadAset not found" {
+		t.Errorf("Expected the bare not found error, got %q", response.Message)
+	}
+
+	stub.MockTransactionStart("tx2")
+	err = contract.SetStrictMode(newTestContext(stub, "Org1MSP", admin), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub.MockTransactionEnd("tx2")
+
+	response = stub.MockInvoke("tx3", [][]byte{[]byte("SmartContract:ReadAset"), []byte("asset1")})
+	if response.Status == shim.OK || !strings.HasPrefix(response.Message, "Function ReadAset not found. Available functions: ") {
+		t.Fatalf("Expected the error listing the functions, got %q", response.Message)
+	}
+	if !strings.Contains(response.Message, " ReadAsset,") {
+		t.Error("The available functions do not include ReadAsset")
+	}
+	if strings.Contains(response.Message, "GetInfo") {
+		t.Error("The available functions include the methods inherited from the contract")
+	}
+}
+
+func TestReadAssetComputed(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "computed", "Org1MSP")
+	err := s.SubmitForApproval(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub.now += 42
+	stub.begin("approve")
+	err = s.ApproveRequestOne(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	view, err := s.ReadAssetComputed(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if view.Asset.ID != "asset1" || view.IsRegistered || view.AgeSeconds != 42 || view.Status != "approved_one" {
+		t.Errorf("Unexpected derived fields %+v", view)
+	}
+	if view.ApprovalProgress.ApprovalsGranted != 1 || view.ApprovalProgress.Percentage != 50 {
+		t.Errorf("Unexpected approval progress %+v", view.ApprovalProgress)
+	}
+}
+
+func TestTransferAllowlist(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "restricted", "Org1MSP")
+	mustCreateAsset(t, ctx, "asset2", "open", "Org1MSP")
+
+	err := s.SetTransferAllowlist(ctx, "asset1", []string{"Org2MSP", "Org3MSP"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.TransferAsset(ctx, "asset1", "Org4MSP")
+	if err == nil || !strings.Contains(err.Error(), "allowlist") {
+		t.Errorf("Expected the allowlist to reject Org4MSP, got %v", err)
+	}
+	err = s.SellAsset(ctx, "asset1", "Org4MSP", 10)
+	if err == nil {
+		t.Error("A sale bypassed the transfer allowlist")
+	}
+
+	err = s.TransferAsset(ctx, "asset1", "Org3MSP")
+	if err != nil {
+		t.Errorf("Expected the transfer to an allowlisted owner to succeed, got %s", err)
+	}
+	err = s.TransferAsset(ctx, "asset2", "Org4MSP")
+	if err != nil {
+		t.Errorf("Expected an empty allowlist to leave transfers open, got %s", err)
+	}
+}
+
+func TestSetTransferAllowlistRequiresOwner(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	mustCreateAsset(t, newTestContext(stub, "Org1MSP", admin), "asset1", "restricted", "Org1MSP")
+
+	err := s.SetTransferAllowlist(newTestContext(stub, "Org2MSP", member), "asset1", []string{"Org2MSP"})
+	if err == nil {
+		t.Error("A client other than the owner set the transfer allowlist")
+	}
+}
+
+func TestGetAssetsModifiedBetween(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	at := func(seconds int64) string {
+		return time.Unix(seconds, 0).UTC().Format(time.RFC3339)
+	}
+	mustCreateAsset(t, ctx, "before", "before the window", "Org1MSP")
+	stub.now = 2000
+	mustCreateAsset(t, ctx, "start", "at the start", "Org1MSP")
+	stub.now = 2500
+	mustCreateAsset(t, ctx, "end", "at the end", "Org1MSP")
+	stub.now = 2501
+	mustCreateAsset(t, ctx, "after", "after the window", "Org1MSP")
+
+	results, err := s.GetAssetsModifiedBetween(ctx, at(2000), at(2500))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Key != "end" || results[1].Key != "start" {
+		t.Errorf("Expected the assets written on both bounds, got %+v", results)
+	}
+
+	_, err = s.GetAssetsModifiedBetween(ctx, at(2500), at(2000))
+	if err == nil {
+		t.Error("A window ending before it starts was accepted")
+	}
+	_, err = s.GetAssetsModifiedBetween(ctx, "yesterday", at(2000))
+	if err == nil {
+		t.Error("A start that is not RFC 3339 was accepted")
+	}
+}
+
+func TestIdenticalUpdateIsNotWritten(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+	stub.now += 10
+
+	err := s.UpdateAsset(ctx, "asset1", "first", "Org1MSP", 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stub.history["asset1"]) != 1 {
+		t.Errorf("Expected the identical update to add no history entry, got %d entries", len(stub.history["asset1"]))
+	}
+	asset, err := s.ReadAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.UpdatedAt.Unix() != 1000 {
+		t.Errorf("Expected the update time to stay at 1000, got %d", asset.UpdatedAt.Unix())
+	}
+
+	err = s.UpdateAsset(ctx, "asset1", "changed", "Org1MSP", 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stub.history["asset1"]) != 2 {
+		t.Errorf("Expected the change to add a history entry, got %d entries", len(stub.history["asset1"]))
+	}
+}
+
+func TestRepeatedApprovalIsNotWritten(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	approver := newTestContext(stub, "Org2MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+	err := s.SetRequiredApprovers(ctx, "asset1", []string{"Org1MSP", "Org2MSP", "Org3MSP"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SubmitForApproval(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.ApproveRequestOne(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.ApproveRequestTwo(approver, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writes := len(stub.history["asset1"])
+
+	err = s.ApproveRequestTwo(approver, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stub.history["asset1"]) != writes {
+		t.Error("A repeated signature wrote the asset again")
+	}
+}
+
+func TestGetOwnerAssetsSorted(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset2", "oldest", "Org1MSP")
+	stub.now += 5
+	mustCreateAsset(t, ctx, "asset1", "middle", "Org1MSP")
+	mustCreateAsset(t, ctx, "asset9", "other owner", "Org2MSP")
+	stub.now += 5
+	mustCreateAsset(t, ctx, "asset3", "newest", "Org1MSP")
+
+	for order, want := range map[string]string{"asc": "asset2 asset1 asset3", "desc": "asset3 asset1 asset2"} {
+		results, err := s.GetOwnerAssetsSorted(ctx, "Org1MSP", order)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys := []string{}
+		for _, result := range results {
+			keys = append(keys, result.Key)
+		}
+		if strings.Join(keys, " ") != want {
+			t.Errorf("Expected %s for %s, got %v", want, order, keys)
+		}
+	}
+
+	_, err := s.GetOwnerAssetsSorted(ctx, "Org1MSP", "up")
+	if err == nil {
+		t.Error("An unknown order was accepted")
+	}
+}
+
+func TestAssetCreatedEventEnvelope(t *testing.T) {
+	stub := newTestStub()
+	stub.begin("create")
+	mustCreateAsset(t, newTestContext(stub, "Org1MSP", admin), "asset1", "first", "Org1MSP")
+
+	var envelope map[string]json.RawMessage
+	err := json.Unmarshal(stub.events["AssetCreated"], &envelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(envelope["eventType"]) != `"AssetCreated"` || string(envelope["schemaVersion"]) != "1" {
+		t.Errorf("Expected a version 1 AssetCreated envelope, got %s", stub.events["AssetCreated"])
+	}
+	if string(envelope["txID"]) != `"create"` || string(envelope["ID"]) != `"asset1"` || envelope["timestamp"] == nil {
+		t.Errorf("Expected the transaction, asset and time, got %s", stub.events["AssetCreated"])
+	}
+
+	var data Asset
+	err = json.Unmarshal(envelope["data"], &data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.ID != "asset1" || data.Owner != "Org1MSP" {
+		t.Errorf("Expected the created asset as data, got %+v", data)
+	}
+}
+
+func TestGetAssetCountSince(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "before", "Org1MSP")
+	stub.now = 2000
+	mustCreateAsset(t, ctx, "asset2", "at the checkpoint", "Org1MSP")
+	stub.now = 2001
+	mustCreateAsset(t, ctx, "asset3", "after", "Org1MSP")
+	mustCreateAsset(t, ctx, "asset4", "after", "Org1MSP")
+
+	count, err := s.GetAssetCountSince(ctx, time.Unix(2000, 0).UTC().Format(time.RFC3339))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("Expected the 2 assets created after the checkpoint, got %d", count)
+	}
+
+	_, err = s.GetAssetCountSince(ctx, "yesterday")
+	if err == nil {
+		t.Error("A checkpoint that is not RFC 3339 was accepted")
+	}
+}
+
+// reverseRangeStub returns range query results in descending key order
+type reverseRangeStub struct {
+	*testStub
+}
+
+func (s *reverseRangeStub) GetStateByRange(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	results, err := s.simpleKeys(startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(results)-1; i < j; i, j = i+1, j-1 {
+		results[i], results[j] = results[j], results[i]
+	}
+
+	return &stateIterator{items: results}, nil
+}
+
+func TestGetAllAssetsIsSortedByID(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	for _, id := range []string{"asset3", "asset1", "asset4", "asset2"} {
+		mustCreateAsset(t, ctx, id, "unordered", "Org1MSP")
+	}
+
+	results, err := s.GetAllAssets(newTestContext(&reverseRangeStub{stub}, "Org1MSP", admin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []string{}
+	for _, result := range results {
+		keys = append(keys, result.Key)
+	}
+	if strings.Join(keys, " ") != "asset1 asset2 asset3 asset4" {
+		t.Errorf("Expected the assets sorted by ID, got %v", keys)
+	}
+}
+
+func TestGetAmountStatistics(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+
+	stats, err := s.GetAmountStatistics(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *stats != (AmountStats{}) {
+		t.Errorf("Expected zeros for an empty ledger, got %+v", stats)
+	}
+
+	for id, amount := range map[string]int{"asset1": 5, "asset2": 20, "asset3": 1, "asset4": 0} {
+		mustCreateAsset(t, ctx, id, "counted", "Org1MSP")
+		err = s.SetAmount(ctx, id, amount)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err = s.GetAmountStatistics(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *stats != (AmountStats{Count: 4, Min: 0, Max: 20, Sum: 26, Average: 6.5}) {
+		t.Errorf("Unexpected statistics %+v", stats)
+	}
+}
+
+func TestGetRecentRegistrations(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	for _, id := range []string{"oldest", "middle", "newest"} {
+		err := s.RegisterAssetDirectly(ctx, id, "registered", "Org1MSP")
+		if err != nil {
+			t.Fatal(err)
+		}
+		stub.now += 5
+	}
+	mustCreateAsset(t, ctx, "draft", "never registered", "Org1MSP")
+
+	results, err := s.GetRecentRegistrations(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Key != "newest" || results[1].Key != "middle" {
+		t.Errorf("Expected newest then middle, got %+v", results)
+	}
+
+	_, err = s.GetRecentRegistrations(ctx, 0)
+	if err == nil {
+		t.Error("A count of zero was accepted")
+	}
+}
+
+func TestGetRecentRegistrationsCapsCount(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	for i := 0; i <= maxRecentRegistrations; i++ {
+		err := s.RegisterAssetDirectly(ctx, fmt.Sprintf("asset%03d", i), "registered", "Org1MSP")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := s.GetRecentRegistrations(ctx, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != maxRecentRegistrations {
+		t.Errorf("Expected the count to be capped at %d, got %d", maxRecentRegistrations, len(results))
+	}
+}
+
+func TestRepairIndexesRemovesOrphans(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+	mustCreateAsset(t, ctx, "asset2", "second", "Org1MSP")
+	err := s.SetExternalRef(ctx, "asset1", "REF-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = putIndexEntry(ctx, extRefIndex, "REF-OLD", "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = putIndexEntry(ctx, lowerIDIndex, "deleted", "deleted")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := s.VerifyIndexes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []IndexEntry{{extRefIndex, "REF-OLD", "asset1"}, {lowerIDIndex, "deleted", "deleted"}}
+	if report.Checked != 5 || len(report.Orphans) != 2 || report.Orphans[0] != want[0] || report.Orphans[1] != want[1] {
+		t.Errorf("Expected 5 entries checked and the 2 seeded orphans, got %+v", report)
+	}
+
+	_, err = s.RepairIndexes(newTestContext(stub, "Org2MSP", member))
+	if err == nil {
+		t.Error("A client other than an admin repaired the indexes")
+	}
+	removed, err := s.RepairIndexes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 {
+		t.Errorf("Expected 2 orphans removed, got %d", removed)
+	}
+
+	report, err = s.VerifyIndexes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Checked != 3 || len(report.Orphans) != 0 {
+		t.Errorf("Expected 3 entries checked and no orphans after the repair, got %+v", report)
+	}
+	asset, err := s.GetAssetByExternalRef(ctx, "REF-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.ID != "asset1" {
+		t.Errorf("Expected the live entry to survive the repair, got %s", asset.ID)
+	}
+}
+
+func TestGetAssetsApprovedBy(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	for _, id := range []string{"asset1", "asset2", "asset3"} {
+		mustCreateAsset(t, ctx, id, "approval", "Org1MSP")
+	}
+	for _, id := range []string{"asset1", "asset2"} {
+		err := s.SubmitForApproval(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := s.ApproveRequestOne(newTestContext(stub, "Org2MSP", admin), "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.ApproveRequestOne(ctx, "asset2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.ResetApprovals(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for mspID, want := range map[string]string{"Org2MSP": "asset1", "Org1MSP": "asset2"} {
+		results, err := s.GetAssetsApprovedBy(ctx, mspID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].Key != want {
+			t.Errorf("Expected only %s approved by %s, got %+v", want, mspID, results)
+		}
+	}
+}
+
+func TestFreezeAssetBlocksChanges(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+
+	err := s.FreezeAsset(newTestContext(stub, "Org2MSP", member), "asset1")
+	if err == nil {
+		t.Error("A client other than an admin froze the asset")
+	}
+	err = s.FreezeAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, err := range map[string]error{
+		"UpdateAsset":   s.UpdateAsset(ctx, "asset1", "changed", "Org1MSP", 0, 0, 0),
+		"TransferAsset": s.TransferAsset(ctx, "asset1", "Org2MSP"),
+		"DeleteAsset":   s.DeleteAsset(ctx, "asset1"),
+		"AddComment":    s.AddComment(ctx, "asset1", "hello"),
+	} {
+		if err == nil || !strings.Contains(err.Error(), "frozen") {
+			t.Errorf("Expected %s to fail on a frozen asset, got %v", name, err)
+		}
+	}
+
+	err = s.UnfreezeAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.TransferAsset(ctx, "asset1", "Org2MSP")
+	if err != nil {
+		t.Errorf("Expected the transfer to work once unfrozen, got %s", err)
+	}
+	err = s.UnfreezeAsset(ctx, "asset1")
+	if err == nil {
+		t.Error("An asset that is not frozen was unfrozen")
+	}
+}
+
+func TestQueryAssetsByField(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+	mustCreateAsset(t, ctx, "asset2", "second", "Org2MSP")
+	err := s.RegisterAssetDirectly(ctx, "asset3", "third", "Org2MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := s.QueryAssetsByField(ctx, "owner", "Org2MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Errorf("Expected the 2 assets owned by Org2MSP, got %+v", results)
+	}
+	results, err = s.QueryAssetsByField(ctx, "status", string(StateRegistered))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Key != "asset3" {
+		t.Errorf("Expected only the registered asset3, got %+v", results)
+	}
+
+	_, err = s.QueryAssetsByField(ctx, "checksum", "abc")
+	if err == nil || !strings.Contains(err.Error(), "owner") {
+		t.Errorf("Expected an error listing the queryable fields, got %v", err)
+	}
+}
+
+func TestGetAssetsLimited(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	for i := 1; i <= 7; i++ {
+		mustCreateAsset(t, ctx, fmt.Sprintf("asset%d", i), "limited", "Org1MSP")
+	}
+
+	result, err := s.GetAssetsLimited(ctx, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Records) != 3 || !result.HasMore || result.Records[0].Key != "asset1" || result.Records[2].Key != "asset3" {
+		t.Errorf("Expected asset1 to asset3 with more to follow, got %+v", result)
+	}
+	result, err = s.GetAssetsLimited(ctx, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Records) != 7 || result.HasMore {
+		t.Errorf("Expected all 7 assets and nothing more, got %+v", result)
+	}
+
+	for _, limit := range []int{0, -1, maxAssetsLimit + 1} {
+		_, err = s.GetAssetsLimited(ctx, limit)
+		if err == nil {
+			t.Errorf("The limit %d was accepted", limit)
+		}
+	}
+}
+
+func TestResetApprovalsOnTransfer(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	for _, id := range []string{"asset1", "asset2"} {
+		err := s.RegisterAssetDirectly(ctx, id, "registered", "Org1MSP")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := s.TransferAsset(ctx, "asset1", "Org2MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset, err := s.ReadAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Registered != 1 || asset.ApprovalOne != 1 || asset.Status != StateRegistered {
+		t.Errorf("Expected the approvals to survive the transfer by default, got %+v", asset)
+	}
+
+	err = s.SetResetApprovalsOnTransfer(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.TransferAsset(ctx, "asset2", "Org2MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset, err = s.ReadAsset(ctx, "asset2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Owner != "Org2MSP" || asset.Registered != 0 || asset.ApprovalOne != 0 || asset.ApprovalTwo != 0 || asset.Status != StateDraft {
+		t.Errorf("Expected the transfer to reset the approvals, got %+v", asset)
+	}
+	if asset.ApprovalLog[len(asset.ApprovalLog)-1].ApprovalType != "reset" {
+		t.Errorf("Expected the reset in the approval log, got %+v", asset.ApprovalLog)
+	}
+}
+
+func TestReadAssetsDetailed(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+	mustCreateAsset(t, ctx, "asset2", "second", "Org1MSP")
+
+	result, err := s.ReadAssetsDetailed(ctx, `["asset2","missing","asset1","asset2","~config~aclMode"]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Found) != 2 || result.Found[0].Key != "asset2" || result.Found[1].Record.ID != "asset1" {
+		t.Errorf("Expected asset2 then asset1 found once each, got %+v", result.Found)
+	}
+	if len(result.NotFound) != 2 || result.NotFound[0] != "missing" {
+		t.Errorf("Expected the missing and the reserved IDs not found, got %v", result.NotFound)
+	}
+
+	_, err = s.ReadAssetsDetailed(ctx, `"asset1"`)
+	if err == nil {
+		t.Error("IDs that are not a JSON array were accepted")
+	}
+}
+
+func TestGetAssetsRegisteredByPair(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	org2, org3 := newTestContext(stub, "Org2MSP", admin), newTestContext(stub, "Org3MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+	mustCreateAsset(t, ctx, "asset2", "second", "Org1MSP")
+	err := s.RegisterAssetDirectly(ctx, "asset3", "third", "Org1MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, approvers := range map[string][2]*contractapi.TransactionContext{"asset1": {org2, ctx}, "asset2": {ctx, org3}} {
+		err = s.SubmitForApproval(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.ApproveRequestOne(approvers[0], id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.ApproveRequestTwo(approvers[1], id)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	asset, err := s.ReadAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.RegisteredByMSPs != [2]string{"Org2MSP", "Org1MSP"} {
+		t.Errorf("Expected Org2MSP and Org1MSP to have registered asset1, got %v", asset.RegisteredByMSPs)
+	}
+	for _, pair := range [][2]string{{"Org1MSP", "Org2MSP"}, {"Org2MSP", "Org1MSP"}} {
+		results, err := s.GetAssetsRegisteredByPair(ctx, pair[0], pair[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].Key != "asset1" {
+			t.Errorf("Expected only asset1 for %v, got %+v", pair, results)
+		}
+	}
+	results, err := s.GetAssetsRegisteredByPair(ctx, "Org1MSP", "Org1MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Key != "asset3" {
+		t.Errorf("Expected only the directly registered asset3, got %+v", results)
+	}
+
+	err = s.ResetApprovals(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset, err = s.ReadAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.RegisteredByMSPs != [2]string{} {
+		t.Errorf("Expected the reset to drop the registering MSPs, got %v", asset.RegisteredByMSPs)
+	}
+}
+
+func TestSortedExportsAreIdentical(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	for _, id := range []string{"asset3", "asset1", "asset2"} {
+		mustCreateAsset(t, ctx, id, "exported", "Org1MSP")
+	}
+	err := s.SetReferences(ctx, "asset1", []string{"asset3", "asset2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reversed := newTestContext(&reverseRangeStub{stub}, "Org1MSP", admin)
+
+	rows, err := s.ExportAssets(ctx, `["ID","owner"]`, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reversedRows, err := s.ExportAssets(reversed, `["ID","owner"]`, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := json.Marshal(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := json.Marshal(reversedRows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(first) != string(second) || rows[0]["ID"] != "asset1" {
+		t.Errorf("Expected identical exports sorted by ID, got %s and %s", first, second)
+	}
+
+	graph, err := s.ExportAssetGraph(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reversedGraph, err := s.ExportAssetGraph(reversed, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if graph != reversedGraph {
+		t.Errorf("Expected identical graphs, got %s and %s", graph, reversedGraph)
+	}
+}
+
+func TestGetAssetsEverOwnedBy(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "transferred", "Org1MSP")
+	mustCreateAsset(t, ctx, "asset2", "kept", "Org2MSP")
+	err := s.TransferAsset(ctx, "asset1", "Org3MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for owner, want := range map[string]string{"Org1MSP": "asset1", "Org3MSP": "asset1", "Org2MSP": "asset2"} {
+		results, err := s.GetAssetsEverOwnedBy(ctx, owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].Key != want {
+			t.Errorf("Expected only %s for %s, got %+v", want, owner, results)
+		}
+	}
+
+	results, err := s.GetAssetsEverOwnedBy(ctx, "Org9MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results == nil || len(results) != 0 {
+		t.Errorf("Expected an empty list for an owner that never held an asset, got %+v", results)
+	}
+}
+
+func TestSimulateTransfer(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+
+	result, err := s.SimulateTransfer(ctx, "asset1", "Org2MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.WouldSucceed || len(result.Reasons) != 0 {
+		t.Errorf("Expected the transfer to succeed, got %+v", result)
+	}
+
+	err = s.SetTransferAllowlist(ctx, "asset1", []string{"Org3MSP"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.FreezeAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err = s.SimulateTransfer(ctx, "asset1", "Org2MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.WouldSucceed || len(result.Reasons) != 2 {
+		t.Errorf("Expected the freeze and the allowlist to block the transfer, got %+v", result)
+	}
+	result, err = s.SimulateTransfer(newTestContext(stub, "Org9MSP", member), "asset1", "Org3MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.WouldSucceed || len(result.Reasons) != 2 || !strings.Contains(result.Reasons[0], "not authorized") {
+		t.Errorf("Expected the caller and the freeze to block the transfer, got %+v", result)
+	}
+	result, err = s.SimulateTransfer(ctx, "missing", "Org2MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.WouldSucceed || len(result.Reasons) != 1 {
+		t.Errorf("Expected the missing asset to block the transfer, got %+v", result)
+	}
+
+	asset, err := s.ReadAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Owner != "Org1MSP" {
+		t.Errorf("Expected the simulation to leave the owner alone, got %s", asset.Owner)
+	}
+}
+
+func TestGetAssetsByCreationSource(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "manual", "Org1MSP")
+	id, err := s.CreateAssetAutoID(ctx, "auto", "Org1MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for source, want := range map[string]string{"manual": "asset1", "auto": id} {
+		results, err := s.GetAssetsByCreationSource(ctx, source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].Key != want {
+			t.Errorf("Expected only %s created %s, got %+v", want, source, results)
+		}
+	}
+
+	_, err = s.GetAssetsByCreationSource(ctx, "imported")
+	if err == nil {
+		t.Error("An unknown creation source was accepted")
+	}
+}
+
+func TestInitLedgerStampsSampleAssets(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	err := s.InitLedger(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := s.GetAssetsByCreationSource(ctx, "manual")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Record.Status != StateDraft {
+		t.Errorf("Expected the 2 sample assets as manual drafts, got %+v", results)
+	}
+
+	var event LedgerInitializedEvent
+	err = json.Unmarshal(stub.events["LedgerInitialized"], &event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(event.IDs, " ") != "asset1 asset2" {
+		t.Errorf("Expected the event to list both sample assets, got %v", event.IDs)
+	}
+	if stub.events["AssetCreated"] != nil {
+		t.Error("InitLedger emitted an AssetCreated event for a single sample asset")
+	}
+}
+
+func TestUpdateAssets(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+	mustCreateAsset(t, ctx, "asset2", "second", "Org1MSP")
+	asset, err := s.ReadAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset.Description = "fixed"
+	assetJSON, err := json.Marshal(asset)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := s.UpdateAssets(ctx, `[`+string(assetJSON)+`,{"ID":"asset2","description":"moved","owner":"Org2MSP"}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 assets updated, got %d", count)
+	}
+	asset, err = s.ReadAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved, err := s.ReadAsset(ctx, "asset2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Description != "fixed" || moved.Owner != "Org2MSP" {
+		t.Errorf("Expected both updates to be written, got %+v and %+v", asset, moved)
+	}
+}
+
+func TestUpdateAssetsAbortsOnMissingIDs(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+
+	_, err := s.UpdateAssets(ctx, `[{"ID":"asset1","description":"changed","owner":"Org1MSP"},{"ID":"missing1"},{"ID":"missing2"}]`)
+	if err == nil || !strings.Contains(err.Error(), "missing1, missing2") {
+		t.Errorf("Expected both missing IDs to be reported, got %v", err)
+	}
+	asset, err := s.ReadAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Description != "first" {
+		t.Errorf("Expected nothing to be written, got %+v", asset)
+	}
+
+	_, err = s.UpdateAssets(ctx, `[{"ID":"asset1"},{"ID":"asset1"}]`)
+	if err == nil {
+		t.Error("An asset listed twice was accepted")
+	}
+}
+
+func TestGetChaincodeInfo(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	err := s.SetStrictMode(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SetACLMode(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := s.GetChaincodeInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != chaincodeVersion || info.AssetSchemaVersion != assetSchemaVersion {
+		t.Errorf("Expected version %s, got %+v", chaincodeVersion, info)
+	}
+	if strings.Join(info.EnabledFeatures, " ") != aclModeConfig+" "+strictModeConfig {
+		t.Errorf("Expected ACL and strict mode enabled, got %v", info.EnabledFeatures)
+	}
+}
+
+func TestUpdateAssetRejectsApprovalFlagsWithoutAdmin(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", member)
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+	err := s.SetRequiredApprovers(ctx, "asset1", []string{"Org2MSP", "Org3MSP"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, flags := range [][3]int{{1, 0, 0}, {1, 1, 0}, {1, 1, 1}} {
+		err = s.UpdateAsset(ctx, "asset1", "first", "Org1MSP", flags[0], flags[1], flags[2])
+		if err == nil {
+			t.Errorf("The owner set the approval flags %v without approving", flags)
+		}
+	}
+	_, err = s.UpdateAssets(ctx, `[{"ID":"asset1","description":"first","owner":"Org1MSP","approvalOne":1,"approvalTwo":1,"registered":1}]`)
+	if err == nil {
+		t.Error("UpdateAssets set the approval flags without approving")
+	}
+	_, err = s.ExecuteBatch(ctx, `[{"op":"update","ID":"asset1","description":"first","owner":"Org1MSP","approvalOne":1,"approvalTwo":1,"registered":1}]`)
+	if err == nil {
+		t.Error("ExecuteBatch set the approval flags without approving")
+	}
+
+	asset, err := s.ReadAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Registered != 0 || asset.ApprovalOne != 0 || asset.Status != StateDraft {
+		t.Errorf("Expected the asset to stay a draft, got %+v", asset)
+	}
+
+	err = s.UpdateAsset(ctx, "asset1", "changed", "Org1MSP", 0, 0, 0)
+	if err != nil {
+		t.Errorf("Expected an update leaving the flags alone to succeed, got %s", err)
+	}
+}
+
+func TestRegistrationWithoutAdminIsLogged(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", member)
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+
+	err := s.UpdateAsset(ctx, "asset1", "first", "Org1MSP", 1, 1, 1)
+	if err == nil {
+		t.Error("The owner registered the asset without approvals")
+	}
+
+	err = s.SubmitForApproval(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.ApproveRequestOne(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.ApproveRequestTwo(newTestContext(stub, "Org2MSP", member), "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	asset, err := s.ReadAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	log, err := s.GetApprovalLog(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Registered != 1 || len(log) != 2 {
+		t.Errorf("Expected the registration to leave 2 approval records, got %+v", log)
+	}
+}
+
+func TestSellAssetRequiresOwner(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "for sale", "Org1MSP")
+
+	err := s.SellAsset(newTestContext(stub, "Org9MSP", member), "asset1", "Org9MSP", 10)
+	if err == nil {
+		t.Error("A client other than the owner sold the asset")
+	}
+
+	asset, err := s.ReadAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Owner != "Org1MSP" || len(asset.SaleLog) != 0 {
+		t.Errorf("Expected the asset to be unsold, got %+v", asset)
+	}
+}
+
+func TestTransferAssetRequiresOwner(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	mustCreateAsset(t, newTestContext(stub, "Org1MSP", admin), "asset1", "first", "Org1MSP")
+
+	err := s.TransferAsset(newTestContext(stub, "Org9MSP", member), "asset1", "Org9MSP")
+	if err == nil {
+		t.Error("A client other than the owner transferred the asset")
+	}
+	err = s.TransferAsset(newTestContext(stub, "Org1MSP", member), "asset1", "Org2MSP")
+	if err != nil {
+		t.Errorf("Expected the owner to transfer the asset, got %s", err)
+	}
+}
+
+func TestMigrateAssetsBackfillsFrozenAssets(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	err := stub.PutState("frozen", []byte(`{"ID":"frozen","description":"old","owner":"Org1MSP","frozen":true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = stub.PutState("legacy", []byte(`{"ID":"legacy","description":"old","owner":"Org1MSP"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub.now += 3600
+
+	migrated, err := s.MigrateAssets(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated != 2 {
+		t.Errorf("Expected the frozen and the legacy record to be migrated, got %d", migrated)
+	}
+	asset, err := s.ReadAsset(ctx, "frozen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !asset.Frozen || asset.CreatedAt.IsZero() || asset.Status != StateDraft || asset.Checksum == "" {
+		t.Errorf("Expected the frozen asset to be backfilled and stay frozen, got %+v", asset)
+	}
+
+	err = s.UpdateAsset(ctx, "frozen", "changed", "Org1MSP", 0, 0, 0)
+	if err == nil || !strings.Contains(err.Error(), "frozen") {
+		t.Errorf("Expected the migrated asset to stay frozen against changes, got %v", err)
+	}
+}
+
+func TestRichQueriesMigrateSingleOwnerRecords(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+	err := stub.PutState("legacy", []byte(`{"ID":"legacy","description":"old","owner":"Org1MSP"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := s.QueryAssetsByField(ctx, "owner", "Org1MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || strings.Join(results[0].Record.Owners, ",") != "Org1MSP" {
+		t.Errorf("Expected the legacy record with its owner listed in owners, got %+v", results)
+	}
+}
@@ -0,0 +1,34 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// maintenanceModeConfig names the configuration entry pausing every change to assets, indexes and counters
+const maintenanceModeConfig = "maintenanceMode"
+
+// SetMaintenanceMode pauses every transaction that would change an asset while enabled; queries keep working.
+// Configuration entries, this one included, can still be changed. Only admins may change it.
+func (s *SmartContract) SetMaintenanceMode(ctx contractapi.TransactionContextInterface, enabled bool) error {
+	return setConfigBool(ctx, maintenanceModeConfig, enabled)
+}
+
+// requireWritable returns an error while the chaincode is in maintenance mode. Every helper writing asset,
+// index or counter state calls it, so no transaction needs to check for maintenance mode itself.
+func requireWritable(ctx contractapi.TransactionContextInterface) error {
+	paused, err := getConfigBool(ctx, maintenanceModeConfig)
+	if err != nil {
+		return err
+	}
+	if paused {
+		return fmt.Errorf("The chaincode is in maintenance mode and does not accept changes")
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSetMaintenanceModeRequiresAdmin(t *testing.T) {
+	err := new(SmartContract).SetMaintenanceMode(newTestContext(newTestStub(), "Org2MSP", member), true)
+	if err == nil {
+		t.Error("A client other than an admin enabled maintenance mode")
+	}
+}
+
+func TestMaintenanceModeBlocksChanges(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "first", "Org1MSP")
+	err := s.SetMaintenanceMode(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, err := range map[string]error{
+		"CreateAsset":    s.CreateAsset(ctx, "asset2", "second", "Org1MSP", 0, 0, 0),
+		"DeleteAsset":    s.DeleteAsset(ctx, "asset1"),
+		"TransferAsset":  s.TransferAsset(ctx, "asset1", "Org2MSP"),
+		"SetExternalRef": s.SetExternalRef(ctx, "asset1", "REF-1"),
+	} {
+		if err == nil || !strings.Contains(err.Error(), "maintenance mode") {
+			t.Errorf("Expected %s to fail in maintenance mode, got %v", name, err)
+		}
+	}
+	_, err = s.CreateAssetAutoID(ctx, "second", "Org1MSP")
+	if err == nil {
+		t.Error("CreateAssetAutoID created an asset in maintenance mode")
+	}
+
+	asset, err := s.ReadAsset(ctx, "asset1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Owner != "Org1MSP" {
+		t.Errorf("Expected the asset to be unchanged, got %+v", asset)
+	}
+	results, err := s.GetAllAssets(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Errorf("Expected queries to keep working, got %+v", results)
+	}
+
+	err = s.SetMaintenanceMode(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.CreateAsset(ctx, "asset2", "second", "Org1MSP", 0, 0, 0)
+	if err != nil {
+		t.Errorf("Expected creates to work again after maintenance, got %s", err)
+	}
+}
@@ -0,0 +1,376 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// testIdentity is a client identity with a fixed MSP and attributes
+type testIdentity struct {
+	mspID string
+	attrs map[string]string
+}
+
+func (i *testIdentity) GetID() (string, error) {
+	return "x509::CN=user," + i.mspID, nil
+}
+
+func (i *testIdentity) GetMSPID() (string, error) {
+	return i.mspID, nil
+}
+
+func (i *testIdentity) GetAttributeValue(name string) (string, bool, error) {
+	value, ok := i.attrs[name]
+	return value, ok, nil
+}
+
+func (i *testIdentity) AssertAttributeValue(name, value string) error {
+	if i.attrs[name] != value {
+		return fmt.Errorf("Attribute %s is not %s", name, value)
+	}
+
+	return nil
+}
+
+func (i *testIdentity) GetX509Certificate() (*x509.Certificate, error) {
+	return nil, nil
+}
+
+// admin holds the attributes of a client carrying the admin attribute
+var admin = map[string]string{"admin": "true"}
+
+// member holds the attributes of a client without any attributes
+var member = map[string]string{}
+
+// testStub extends the mock stub with the parts of the peer the chaincode relies on that the mock leaves out:
+// key history, a settable transaction clock, events, CouchDB rich queries and paginated queries.
+type testStub struct {
+	*shimtest.MockStub
+	history    map[string][]*queryresult.KeyModification
+	now        int64
+	events     map[string][]byte
+	failEvents bool
+}
+
+// newTestStub returns an empty ledger with a transaction already started.
+func newTestStub() *testStub {
+	stub := &testStub{
+		MockStub: shimtest.NewMockStub("utility", nil),
+		history:  map[string][]*queryresult.KeyModification{},
+		now:      1000,
+		events:   map[string][]byte{},
+	}
+	stub.MockTransactionStart("tx0")
+
+	return stub
+}
+
+// newTestContext returns a transaction context over stub for a client of mspID with attrs.
+func newTestContext(stub shim.ChaincodeStubInterface, mspID string, attrs map[string]string) *contractapi.TransactionContext {
+	ctx := new(contractapi.TransactionContext)
+	ctx.SetStub(stub)
+	ctx.SetClientIdentity(&testIdentity{mspID: mspID, attrs: attrs})
+
+	return ctx
+}
+
+// begin starts transaction txID; reads see every write made before it.
+func (s *testStub) begin(txID string) {
+	s.MockTransactionEnd(s.TxID)
+	s.MockTransactionStart(txID)
+}
+
+func (s *testStub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	return &timestamp.Timestamp{Seconds: s.now}, nil
+}
+
+func (s *testStub) SetEvent(name string, payload []byte) error {
+	if s.failEvents {
+		return fmt.Errorf("The event service is unavailable")
+	}
+	s.events[name] = payload
+
+	return nil
+}
+
+func (s *testStub) PutState(key string, value []byte) error {
+	s.history[key] = append(s.history[key], &queryresult.KeyModification{TxId: s.TxID, Value: value, Timestamp: &timestamp.Timestamp{Seconds: s.now}})
+	return s.MockStub.PutState(key, value)
+}
+
+func (s *testStub) DelState(key string) error {
+	s.history[key] = append(s.history[key], &queryresult.KeyModification{TxId: s.TxID, IsDelete: true, Timestamp: &timestamp.Timestamp{Seconds: s.now}})
+	return s.MockStub.DelState(key)
+}
+
+func (s *testStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	return &historyIterator{items: s.history[key]}, nil
+}
+
+func (s *testStub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	results, err := s.runQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stateIterator{items: results}, nil
+}
+
+func (s *testStub) GetQueryResultWithPagination(query string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	results, err := s.runQuery(query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return paginate(results, pageSize, bookmark)
+}
+
+func (s *testStub) GetStateByRangeWithPagination(startKey, endKey string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	results, err := s.simpleKeys(startKey, endKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return paginate(results, pageSize, bookmark)
+}
+
+// simpleKeys returns the entries between startKey and endKey, leaving out composite keys like the peer does.
+func (s *testStub) simpleKeys(startKey, endKey string) ([]*queryresult.KV, error) {
+	resultsIterator, err := s.MockStub.GetStateByRange(startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	results := []*queryresult.KV{}
+	for resultsIterator.HasNext() {
+		result, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(result.Key, compositeKeyNamespace) {
+			results = append(results, result)
+		}
+	}
+
+	return results, nil
+}
+
+// runQuery evaluates the selector of a CouchDB query against every JSON document in the world state.
+func (s *testStub) runQuery(query string) ([]*queryresult.KV, error) {
+	var request struct {
+		Selector map[string]interface{} `json:"selector"`
+	}
+	err := json.Unmarshal([]byte(query), &request)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := s.simpleKeys("", "")
+	if err != nil {
+		return nil, err
+	}
+
+	results := []*queryresult.KV{}
+	for _, entry := range entries {
+		var document map[string]interface{}
+		if json.Unmarshal(entry.Value, &document) == nil && matchesSelector(document, request.Selector) {
+			results = append(results, entry)
+		}
+	}
+
+	return results, nil
+}
+
+// paginate returns the page of results starting at bookmark, resuming like the peer does from the key that
+// did not fit on the previous page.
+func paginate(results []*queryresult.KV, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	page := []*queryresult.KV{}
+	next := ""
+	for _, result := range results {
+		if result.Key < bookmark {
+			continue
+		}
+		if int32(len(page)) == pageSize {
+			next = result.Key
+			break
+		}
+		page = append(page, result)
+	}
+
+	return &stateIterator{items: page}, &peer.QueryResponseMetadata{FetchedRecordsCount: int32(len(page)), Bookmark: next}, nil
+}
+
+// matchesSelector reports whether document satisfies the subset of the CouchDB selector syntax the chaincode uses.
+func matchesSelector(document map[string]interface{}, selector map[string]interface{}) bool {
+	for field, condition := range selector {
+		switch field {
+		case "$and", "$or":
+			matched := 0
+			clauses := condition.([]interface{})
+			for _, clause := range clauses {
+				if matchesSelector(document, clause.(map[string]interface{})) {
+					matched++
+				}
+			}
+			if field == "$and" && matched != len(clauses) || field == "$or" && matched == 0 {
+				return false
+			}
+			continue
+		}
+
+		value, present := lookupField(document, field)
+		operators, ok := condition.(map[string]interface{})
+		if !ok {
+			operators = map[string]interface{}{"$eq": condition}
+		}
+		for operator, argument := range operators {
+			if !matchesOperator(value, present, operator, argument) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func matchesOperator(value interface{}, present bool, operator string, argument interface{}) bool {
+	switch operator {
+	case "$exists":
+		return present == argument.(bool)
+	case "$eq":
+		return present && fmt.Sprint(value) == fmt.Sprint(argument)
+	case "$gt", "$gte", "$lt", "$lte":
+		if !present {
+			return false
+		}
+		order := compareValues(value, argument)
+		switch operator {
+		case "$gt":
+			return order > 0
+		case "$gte":
+			return order >= 0
+		case "$lt":
+			return order < 0
+		default:
+			return order <= 0
+		}
+	case "$in":
+		return present && containsValue(argument.([]interface{}), value)
+	case "$all":
+		elements, _ := value.([]interface{})
+		for _, wanted := range argument.([]interface{}) {
+			if !containsValue(elements, wanted) {
+				return false
+			}
+		}
+		return true
+	case "$elemMatch":
+		elements, _ := value.([]interface{})
+		for _, element := range elements {
+			object, ok := element.(map[string]interface{})
+			if ok && matchesSelector(object, argument.(map[string]interface{})) {
+				return true
+			}
+		}
+		return false
+	}
+
+	panic("unsupported selector operator " + operator)
+}
+
+// compareValues orders numbers numerically and everything else by its string form.
+func compareValues(a, b interface{}) int {
+	x, okX := a.(float64)
+	y, okY := b.(float64)
+	if !okX || !okY {
+		return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+	}
+
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+
+	return 0
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, candidate := range values {
+		if fmt.Sprint(candidate) == fmt.Sprint(value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lookupField follows a dotted field name through the nested objects of document.
+func lookupField(document map[string]interface{}, field string) (interface{}, bool) {
+	var value interface{} = document
+	for _, segment := range strings.Split(field, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		value, ok = object[segment]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+// stateIterator iterates over a fixed list of world state entries
+type stateIterator struct {
+	items []*queryresult.KV
+	next  int
+}
+
+func (i *stateIterator) HasNext() bool {
+	return i.next < len(i.items)
+}
+
+func (i *stateIterator) Next() (*queryresult.KV, error) {
+	i.next++
+	return i.items[i.next-1], nil
+}
+
+func (i *stateIterator) Close() error {
+	return nil
+}
+
+// historyIterator iterates over a fixed list of key modifications
+type historyIterator struct {
+	items []*queryresult.KeyModification
+	next  int
+}
+
+func (i *historyIterator) HasNext() bool {
+	return i.next < len(i.items)
+}
+
+func (i *historyIterator) Next() (*queryresult.KeyModification, error) {
+	i.next++
+	return i.items[i.next-1], nil
+}
+
+func (i *historyIterator) Close() error {
+	return nil
+}
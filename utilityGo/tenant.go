@@ -0,0 +1,77 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	tenantIsolationConfig = "tenantIsolation"
+	tenantKeyPrefix       = internalKeyPrefix + "tenant~"
+)
+
+// SetTenantIsolation stores asset keys under a prefix derived from the caller's MSP when enabled, so that
+// each organization only sees its own assets. Assets written before the switch are not moved, so enable
+// it before creating any. Only admins may change it.
+func (s *SmartContract) SetTenantIsolation(ctx contractapi.TransactionContextInterface, enabled bool) error {
+	return setConfigBool(ctx, tenantIsolationConfig, enabled)
+}
+
+// getTenant returns the MSP ID the caller's assets are namespaced by, or "" when isolation is disabled.
+func getTenant(ctx contractapi.TransactionContextInterface) (string, error) {
+	enabled, err := getConfigBool(ctx, tenantIsolationConfig)
+	if err != nil || !enabled {
+		return "", err
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", fmt.Errorf("Failed to get client MSP ID. %s", err.Error())
+	}
+	if mspID == "" || strings.Contains(mspID, "~") {
+		return "", fmt.Errorf("The MSP ID %q cannot be used as a tenant", mspID)
+	}
+
+	return mspID, nil
+}
+
+// tenantPrefix returns the prefix of the world state keys holding the caller's assets.
+func tenantPrefix(ctx contractapi.TransactionContextInterface) (string, error) {
+	tenant, err := getTenant(ctx)
+	if err != nil || tenant == "" {
+		return "", err
+	}
+
+	return tenantKeyPrefix + tenant + "~", nil
+}
+
+// assetKey returns the world state key the caller's asset with given id is stored under.
+func assetKey(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	prefix, err := tenantPrefix(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return prefix + id, nil
+}
+
+// assetIDFromKey returns the asset ID stored under key, reporting false for keys outside prefix and for
+// internal keys such as config entries and counters.
+func assetIDFromKey(prefix, key string) (string, bool) {
+	if !strings.HasPrefix(key, prefix) {
+		return "", false
+	}
+
+	id := strings.TrimPrefix(key, prefix)
+	if isReservedKey(id) {
+		return "", false
+	}
+
+	return id, true
+}
@@ -0,0 +1,125 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestSetTenantIsolationRequiresAdmin(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org2MSP", admin)
+
+	err := s.SetTenantIsolation(ctx, true)
+	if err == nil {
+		t.Error("A client outside the admin MSP turned on tenant isolation")
+	}
+}
+
+func TestTenantIsolation(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	org1, org2 := newTestContext(stub, "Org1MSP", admin), newTestContext(stub, "Org2MSP", admin)
+	err := s.SetTenantIsolation(org1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mustCreateAsset(t, org1, "shared-id", "org1", "Org1MSP")
+	mustCreateAsset(t, org2, "shared-id", "org2", "Org2MSP")
+	mustCreateAsset(t, org2, "org2-only", "org2", "Org2MSP")
+
+	for _, ctx := range []*contractapi.TransactionContext{org1, org2} {
+		mspID, err := ctx.GetClientIdentity().GetMSPID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		asset, err := s.ReadAsset(ctx, "shared-id")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if asset.Owner != mspID {
+			t.Errorf("%s read the asset of %s", mspID, asset.Owner)
+		}
+	}
+
+	exists, err := s.AssetExists(org1, "org2-only")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Error("An asset of another tenant is visible")
+	}
+
+	org1Assets, err := s.GetAllAssets(org1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(org1Assets) != 1 || org1Assets[0].Key != "shared-id" {
+		t.Errorf("Expected only the asset of Org1MSP, got %+v", org1Assets)
+	}
+	org2Assets, err := s.GetAllAssets(org2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(org2Assets) != 2 {
+		t.Errorf("Expected the 2 assets of Org2MSP, got %+v", org2Assets)
+	}
+
+	count, err := s.CountAssets(org1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("Expected Org1MSP to count 1 asset, got %d", count)
+	}
+
+	err = s.DeleteAsset(org1, "shared-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exists, err = s.AssetExists(org2, "shared-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Error("Deleting the asset of one tenant deleted the other's")
+	}
+}
+
+func TestTenantIsolationScopesIndexes(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	org1, org2 := newTestContext(stub, "Org1MSP", admin), newTestContext(stub, "Org2MSP", admin)
+	err := s.SetTenantIsolation(org1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustCreateAsset(t, org1, "shared-id", "org1", "Org1MSP")
+	mustCreateAsset(t, org2, "shared-id", "org2", "Org2MSP")
+	for _, ctx := range []*contractapi.TransactionContext{org1, org2} {
+		err := s.SetExternalRef(ctx, "shared-id", "ERP-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	asset, err := s.GetAssetByExternalRef(org1, "ERP-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Description != "org1" {
+		t.Errorf("Expected the reference of Org1MSP to find its own asset, got %s", asset.Description)
+	}
+	asset, err = s.GetAssetCaseInsensitive(org2, "SHARED-ID")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Description != "org2" {
+		t.Errorf("Expected Org2MSP to find its own asset, got %s", asset.Description)
+	}
+}
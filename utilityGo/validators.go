@@ -0,0 +1,387 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// requiredFieldsConfig names the configuration entry listing the asset fields every write must fill in
+const requiredFieldsConfig = "requiredFields"
+
+// stampedAssetFields lists the asset fields the chaincode fills in only after the validators have run, so a
+// required fields policy cannot ask for them
+var stampedAssetFields = map[string]bool{
+	"updatedAt":        true,
+	"checksum":         true,
+	"registeredAt":     true,
+	"registeredByMSPs": true,
+}
+
+// blocklistConfig names the configuration entry listing the terms asset descriptions must not contain
+const blocklistConfig = "blocklist"
+
+// AssetValidator checks an asset before it is written to the world state and returns an error to abort the write.
+// previous is the version currently stored, or an empty asset when the asset is being created.
+type AssetValidator func(ctx contractapi.TransactionContextInterface, previous, asset *Asset) error
+
+// assetValidators lists the validators run, in order, before every asset write
+var assetValidators = []AssetValidator{
+	validateAssetID,
+	validateUTF8,
+	validateNewAssetOwner,
+	validateEscrowOwners,
+	validateTransferAllowlist,
+	validateNotFrozen,
+	validateRegisteredDescription,
+	validateRequiredFields,
+	validateDescriptionTerms,
+}
+
+// RegisterAssetValidator appends validator to the validators run before every asset write.
+// Validators run in registration order after the built-in ones; register them from an init function.
+func RegisterAssetValidator(validator AssetValidator) {
+	assetValidators = append(assetValidators, validator)
+}
+
+// validateAsset runs every registered validator against asset, stopping at the first error.
+func validateAsset(ctx contractapi.TransactionContextInterface, previous, asset *Asset) error {
+	for _, validator := range assetValidators {
+		err := validator(ctx, previous, asset)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validationErrors runs every registered validator against asset and returns the message of each that fails.
+func validationErrors(ctx contractapi.TransactionContextInterface, previous, asset *Asset) []string {
+	messages := []string{}
+	for _, validator := range assetValidators {
+		err := validator(ctx, previous, asset)
+		if err != nil {
+			messages = append(messages, err.Error())
+		}
+	}
+
+	return messages
+}
+
+// validateAssetID rejects asset IDs that would collide with internal state.
+func validateAssetID(ctx contractapi.TransactionContextInterface, previous, asset *Asset) error {
+	if isReservedKey(asset.ID) {
+		return fmt.Errorf("The asset ID %q is reserved for internal use", asset.ID)
+	}
+
+	return nil
+}
+
+// validateNewAssetOwner holds newly created assets to the owner MSP allowlist.
+func validateNewAssetOwner(ctx contractapi.TransactionContextInterface, previous, asset *Asset) error {
+	if previous.ID != "" {
+		return nil
+	}
+
+	return validateOwnerMSP(ctx, asset.Owner)
+}
+
+// validateEscrowOwners keeps the owners of an asset in escrow fixed until it is released.
+func validateEscrowOwners(ctx contractapi.TransactionContextInterface, previous, asset *Asset) error {
+	if !previous.InEscrow || !asset.InEscrow {
+		return nil
+	}
+
+	if asset.Owner != previous.Owner || strings.Join(asset.Owners, "\x00") != strings.Join(previous.Owners, "\x00") {
+		return fmt.Errorf("The asset %s is in escrow and its owners cannot change until it is released", asset.ID)
+	}
+
+	return nil
+}
+
+// validateTransferAllowlist holds every change of owner, whichever transaction makes it, to the transfer
+// allowlist the asset had before the change.
+func validateTransferAllowlist(ctx contractapi.TransactionContextInterface, previous, asset *Asset) error {
+	if previous.ID == "" || asset.Owner == previous.Owner || len(previous.TransferAllowlist) == 0 {
+		return nil
+	}
+
+	if !containsString(previous.TransferAllowlist, asset.Owner) {
+		return fmt.Errorf("The asset %s cannot be transferred to %s, who is not on its transfer allowlist", asset.ID, asset.Owner)
+	}
+
+	return nil
+}
+
+// validateNotFrozen rejects every change to a frozen asset other than lifting the freeze. Backfilling a record
+// written by an earlier version of the chaincode leaves its content as it is and is let through.
+func validateNotFrozen(ctx contractapi.TransactionContextInterface, previous, asset *Asset) error {
+	if previous.Frozen && asset.Frozen && !onlyBackfilled(previous, asset) {
+		return fmt.Errorf("The asset %s is frozen and cannot change until it is unfrozen", asset.ID)
+	}
+
+	return nil
+}
+
+// onlyBackfilled reports whether asset differs from previous in nothing but the fields backfillAsset fills
+// in where previous lacks them.
+func onlyBackfilled(previous, asset *Asset) bool {
+	expected := *previous
+	migrateOwners(&expected)
+	if expected.Status == "" {
+		expected.Status = stateFromFlags(&expected, StateDraft)
+	}
+	if expected.CreatedAt.IsZero() {
+		expected.CreatedAt = asset.CreatedAt
+	}
+	if expected.RegisteredAt.IsZero() {
+		expected.RegisteredAt = asset.RegisteredAt
+	}
+
+	return reflect.DeepEqual(&expected, asset)
+}
+
+// validateRegisteredDescription keeps the description of a registered asset fixed except through an approved
+// amendment, whichever transaction writes it. Clients carrying the admin attribute may still change it directly.
+func validateRegisteredDescription(ctx contractapi.TransactionContextInterface, previous, asset *Asset) error {
+	if previous.Registered != 1 || asset.Description == previous.Description {
+		return nil
+	}
+
+	// registering an amended asset is what makes the amendment take effect
+	amendment := previous.PendingAmendment
+	if amendment != nil && asset.PendingAmendment == nil && asset.Registered == 1 && asset.Description == amendment.Description {
+		return nil
+	}
+
+	admin, err := hasAdminAttribute(ctx)
+	if err != nil {
+		return err
+	}
+	if !admin {
+		return fmt.Errorf("The asset %s is registered and its description can only change through an amendment", asset.ID)
+	}
+
+	return nil
+}
+
+// SetRequiredFields sets the asset fields every write must fill in, given as a JSON array of JSON field names.
+// A dot reaches into an object, so "metadata.region" requires the region metadata entry. An empty array lifts
+// the policy. Fields the chaincode fills in while writing, such as updatedAt, cannot be required. Assets already
+// stored are only held to it when they are next written. Only admins may change it.
+func (s *SmartContract) SetRequiredFields(ctx contractapi.TransactionContextInterface, fieldsJSON string) error {
+	var fields []string
+	err := json.Unmarshal([]byte(fieldsJSON), &fields)
+	if err != nil {
+		return fmt.Errorf("Required fields must be a JSON array of strings. %s", err.Error())
+	}
+
+	names := assetFieldNames()
+	for _, field := range fields {
+		path := strings.Split(field, ".")
+		if !names[path[0]] {
+			return fmt.Errorf("Unknown asset field %s", path[0])
+		}
+		if stampedAssetFields[path[0]] {
+			return fmt.Errorf("The field %s is filled in by the chaincode and cannot be required", path[0])
+		}
+		for _, segment := range path[1:] {
+			if segment == "" {
+				return fmt.Errorf("The required field %s has an empty path segment", field)
+			}
+		}
+	}
+
+	return setAdminConfig(ctx, requiredFieldsConfig, fieldsJSON)
+}
+
+// validateRequiredFields holds every asset write to the required fields policy set with SetRequiredFields.
+func validateRequiredFields(ctx contractapi.TransactionContextInterface, previous, asset *Asset) error {
+	fieldsJSON, err := getConfig(ctx, requiredFieldsConfig)
+	if err != nil || fieldsJSON == "" {
+		return err
+	}
+
+	var fields []string
+	err = json.Unmarshal([]byte(fieldsJSON), &fields)
+	if err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	document, err := assetDocument(asset)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		if isZeroJSON(lookupJSONPath(document, strings.Split(field, "."))) {
+			return fmt.Errorf("The asset %s is missing the required field %s", asset.ID, field)
+		}
+	}
+
+	return nil
+}
+
+// lookupJSONPath returns the value path leads to through the nested objects of document, or nil when some
+// segment of it is missing or does not name an object.
+func lookupJSONPath(document map[string]json.RawMessage, path []string) json.RawMessage {
+	value := document[path[0]]
+	for _, segment := range path[1:] {
+		var object map[string]json.RawMessage
+		if json.Unmarshal(value, &object) != nil {
+			return nil
+		}
+		value = object[segment]
+	}
+
+	return value
+}
+
+// SetBlocklist sets the terms asset descriptions must not contain, given as a JSON array of strings. Terms are
+// matched case-insensitively against whole words, so "spam" blocks "SPAM" but not "spammer", and a term of several
+// words matches those words in sequence. An empty array lifts the blocklist. Only admins may change it.
+func (s *SmartContract) SetBlocklist(ctx contractapi.TransactionContextInterface, termsJSON string) error {
+	var terms []string
+	err := json.Unmarshal([]byte(termsJSON), &terms)
+	if err != nil {
+		return fmt.Errorf("The blocklist must be a JSON array of strings. %s", err.Error())
+	}
+
+	for _, term := range terms {
+		if len(descriptionWords(term)) == 0 {
+			return fmt.Errorf("The blocklisted term %q holds no words", term)
+		}
+	}
+
+	return setAdminConfig(ctx, blocklistConfig, termsJSON)
+}
+
+// validateDescriptionTerms rejects new and changed descriptions containing a term of the blocklist set with
+// SetBlocklist. Descriptions written before a term was blocklisted are left alone until they change.
+func validateDescriptionTerms(ctx contractapi.TransactionContextInterface, previous, asset *Asset) error {
+	if previous.ID != "" && asset.Description == previous.Description {
+		return nil
+	}
+
+	termsJSON, err := getConfig(ctx, blocklistConfig)
+	if err != nil || termsJSON == "" {
+		return err
+	}
+
+	var terms []string
+	err = json.Unmarshal([]byte(termsJSON), &terms)
+	if err != nil {
+		return err
+	}
+
+	words := descriptionWords(asset.Description)
+	for _, term := range terms {
+		if containsWords(words, descriptionWords(term)) {
+			return fmt.Errorf("The description of asset %s contains the blocklisted term %q", asset.ID, term)
+		}
+	}
+
+	return nil
+}
+
+// descriptionWords splits text into lower case words, treating every character that is not a letter or a
+// digit as a separator.
+func descriptionWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// containsWords reports whether sequence appears as consecutive words of words.
+func containsWords(words, sequence []string) bool {
+	for start := 0; start+len(sequence) <= len(words); start++ {
+		matched := true
+		for i, word := range sequence {
+			if words[start+i] != word {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateUTF8 rejects assets holding a string that is not valid UTF-8, naming the offending field.
+func validateUTF8(ctx contractapi.TransactionContextInterface, previous, asset *Asset) error {
+	field := invalidUTF8Field(reflect.ValueOf(*asset), "")
+	if field != "" {
+		return fmt.Errorf("The field %s of asset %q is not valid UTF-8", field, asset.ID)
+	}
+
+	return nil
+}
+
+// invalidUTF8Field returns the JSON path below path of the first string in value that is not valid UTF-8,
+// or an empty string when all of them are.
+func invalidUTF8Field(value reflect.Value, path string) string {
+	switch value.Kind() {
+	case reflect.String:
+		if !utf8.ValidString(value.String()) {
+			return path
+		}
+	case reflect.Ptr:
+		if !value.IsNil() {
+			return invalidUTF8Field(value.Elem(), path)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			field := invalidUTF8Field(value.Index(i), fmt.Sprintf("%s[%d]", path, i))
+			if field != "" {
+				return field
+			}
+		}
+	case reflect.Map:
+		keys := value.MapKeys()
+		// visit the entries in a fixed order so every peer reports the same field
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, key := range keys {
+			if !utf8.ValidString(key.String()) {
+				return path + " key"
+			}
+			field := invalidUTF8Field(value.MapIndex(key), path+"."+key.String())
+			if field != "" {
+				return field
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			name := strings.Split(value.Type().Field(i).Tag.Get("json"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+
+			field := invalidUTF8Field(value.Field(i), name)
+			if field != "" {
+				return field
+			}
+		}
+	}
+
+	return ""
+}
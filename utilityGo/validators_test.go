@@ -0,0 +1,192 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestRegisterAssetValidatorRunsOnWrites(t *testing.T) {
+	saved := assetValidators
+	defer func() { assetValidators = saved }()
+
+	calls := 0
+	RegisterAssetValidator(func(ctx contractapi.TransactionContextInterface, previous, asset *Asset) error {
+		calls++
+		if asset.Description == "rejected" {
+			return fmt.Errorf("The description is rejected")
+		}
+		return nil
+	})
+
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+
+	err := s.CreateAsset(ctx, "asset1", "accepted", "Org1MSP", 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.CreateAsset(ctx, "asset2", "rejected", "Org1MSP", 0, 0, 0)
+	if err == nil {
+		t.Error("The custom validator did not abort the write")
+	}
+	err = s.UpdateAsset(ctx, "asset1", "rejected", "Org1MSP", 0, 0, 0)
+	if err == nil {
+		t.Error("The custom validator did not abort the update")
+	}
+	if calls != 3 {
+		t.Errorf("Expected the custom validator to run on 3 writes, ran %d times", calls)
+	}
+
+	err = s.CreateAsset(ctx, internalKeyPrefix+"asset", "accepted", "Org1MSP", 0, 0, 0)
+	if err == nil {
+		t.Error("The built-in validators no longer run")
+	}
+	if calls != 3 {
+		t.Error("The custom validator ran after a built-in validator failed")
+	}
+}
+
+func TestValidateUTF8NamesInvalidField(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+
+	err := s.CreateAsset(ctx, "asset1", "bad\xff", "Org1MSP", 0, 0, 0)
+	if err == nil || !strings.Contains(err.Error(), "description") {
+		t.Errorf("Expected the description to be rejected, got %v", err)
+	}
+
+	err = s.CreateAsset(ctx, "asset2", "valid", "Org1MSP", 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SetMetadata(ctx, "asset2", "region", "eu\xc3")
+	if err == nil || !strings.Contains(err.Error(), "metadata.region") {
+		t.Errorf("Expected the metadata entry to be rejected, got %v", err)
+	}
+}
+
+func TestInvalidUTF8FieldVisitsArrays(t *testing.T) {
+	asset := Asset{ID: "asset1", RegisteredByMSPs: [2]string{"Org1MSP", "\xff"}}
+
+	field := invalidUTF8Field(reflect.ValueOf(asset), "")
+	if field != "registeredByMSPs[1]" {
+		t.Errorf("Expected registeredByMSPs[1], got %q", field)
+	}
+}
+
+func TestRequiredFieldsPolicy(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	err := s.SetRequiredFields(ctx, `["description","metadata.region"]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.CreateAsset(ctx, "asset1", "first", "Org1MSP", 0, 0, 0)
+	if err == nil || !strings.Contains(err.Error(), "metadata.region") {
+		t.Errorf("Expected the missing region to be reported, got %v", err)
+	}
+	err = s.CreateAssetRich(ctx, `{"ID":"asset2","owner":"Org1MSP","metadata":{"region":"eu"}}`)
+	if err == nil || !strings.Contains(err.Error(), "description") {
+		t.Errorf("Expected the missing description to be reported, got %v", err)
+	}
+	err = s.CreateAssetRich(ctx, `{"ID":"asset3","description":"third","owner":"Org1MSP","metadata":{"region":"eu"}}`)
+	if err != nil {
+		t.Errorf("Expected an asset with every required field to be created, got %s", err)
+	}
+
+	err = s.SetRequiredFields(ctx, `[]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.CreateAsset(ctx, "asset1", "first", "Org1MSP", 0, 0, 0)
+	if err != nil {
+		t.Errorf("Expected the empty policy to lift the requirements, got %s", err)
+	}
+}
+
+func TestSetRequiredFieldsRejectsBadFields(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	ctx := newTestContext(stub, "Org1MSP", admin)
+
+	err := s.SetRequiredFields(newTestContext(stub, "Org2MSP", member), `["description"]`)
+	if err == nil {
+		t.Error("A client other than an admin set the required fields")
+	}
+	for _, fields := range []string{`["unknown"]`, `["metadata."]`, `"description"`} {
+		err = s.SetRequiredFields(ctx, fields)
+		if err == nil {
+			t.Errorf("The required fields %s were accepted", fields)
+		}
+	}
+	for field := range stampedAssetFields {
+		err = s.SetRequiredFields(ctx, fmt.Sprintf(`[%q]`, field))
+		if err == nil {
+			t.Errorf("The stamped field %s was accepted", field)
+		}
+	}
+
+	err = s.SetRequiredFields(ctx, `["createdAt"]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.CreateAsset(ctx, "asset1", "first", "Org1MSP", 0, 0, 0)
+	if err != nil {
+		t.Errorf("Expected the creation time to be filled in before the policy runs, got %s", err)
+	}
+}
+
+func TestBlocklistRejectsDescriptions(t *testing.T) {
+	s := new(SmartContract)
+	ctx := newTestContext(newTestStub(), "Org1MSP", admin)
+	mustCreateAsset(t, ctx, "asset1", "a darn thing", "Org1MSP")
+	err := s.SetBlocklist(ctx, `["darn","Bad Word"]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, description := range []string{"A DARN, thing", "darned bad-word"} {
+		err = s.CreateAsset(ctx, "asset2", description, "Org1MSP", 0, 0, 0)
+		if err == nil || !strings.Contains(err.Error(), "blocklisted") {
+			t.Errorf("Expected %q to be blocked, got %v", description, err)
+		}
+	}
+	err = s.CreateAsset(ctx, "asset3", "darned badword words", "Org1MSP", 0, 0, 0)
+	if err != nil {
+		t.Errorf("Expected a description without whole blocklisted words to be allowed, got %s", err)
+	}
+	err = s.UpdateAsset(ctx, "asset3", "darn", "Org1MSP", 0, 0, 0)
+	if err == nil {
+		t.Error("An update introduced a blocklisted term")
+	}
+
+	err = s.TransferAsset(ctx, "asset1", "Org2MSP")
+	if err != nil {
+		t.Errorf("Expected a description written before the blocklist to be left alone, got %s", err)
+	}
+}
+
+func TestSetBlocklistValidatesTerms(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+
+	err := s.SetBlocklist(newTestContext(stub, "Org2MSP", member), `["darn"]`)
+	if err == nil {
+		t.Error("A client other than an admin set the blocklist")
+	}
+	for _, terms := range []string{`["darn"," "]`, `"darn"`} {
+		err = s.SetBlocklist(newTestContext(stub, "Org1MSP", admin), terms)
+		if err == nil {
+			t.Errorf("The blocklist %s was accepted", terms)
+		}
+	}
+}